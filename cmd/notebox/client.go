@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Note as represented by the API of the server.
+type note struct {
+	Slug       string     `json:"slug"`
+	URL        string     `json:"url"`
+	Author     string     `json:"author"`
+	Title      string     `json:"title"`
+	Content    *string    `json:"content"`
+	Format     string     `json:"format"`
+	Language   string     `json:"language"`
+	Visibility string     `json:"visibility"`
+	Tags       []string   `json:"tags"`
+	Burn       bool       `json:"burn"`
+	Protected  bool       `json:"protected"`
+	Encrypted  bool       `json:"encrypted"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires"`
+}
+
+type notePage struct {
+	Notes []note `json:"notes"`
+	Prev  string `json:"prev"`
+	Next  string `json:"next"`
+}
+
+// Fields sent to the server to create a note.
+type noteInput struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Format     string   `json:"format,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+	Burn       bool     `json:"burn,omitempty"`
+	Password   string   `json:"password,omitempty"`
+	Expires    string   `json:"expires,omitempty"`
+}
+
+// Error responded by the server, either describing the whole request or each invalid field.
+type apiError struct {
+	Status int
+	Err    string            `json:"error"`
+	Fields map[string]string `json:"errors"`
+}
+
+func (e *apiError) Error() string {
+	if len(e.Fields) == 0 {
+		if e.Err == "" {
+			return fmt.Sprintf("server responded with status %d", e.Status)
+		}
+		return e.Err
+	}
+
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, field+": "+e.Fields[field])
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Client of the API of a notebox server, authenticated through a personal token.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newClient(cfg *config) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &client{
+		server: strings.TrimSuffix(cfg.Server, "/"),
+		token:  cfg.Token,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		},
+	}
+}
+
+// Sends a request to the API, encoding `body` and decoding the response into `dst` as JSON, as long
+// as they aren't nil.
+func (c *client) do(method, path string, body, dst any) error {
+	var r io.Reader
+
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rs, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 400 {
+		e := &apiError{Status: rs.StatusCode}
+		// The body is ignored if it isn't a JSON error, such as when the server is behind a proxy.
+		json.NewDecoder(rs.Body).Decode(e)
+		return e
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rs.Body).Decode(dst)
+}
+
+func (c *client) createNote(input noteInput) (*note, error) {
+	var n note
+
+	err := c.do(http.MethodPost, "/api/v1/notes", input, &n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (c *client) getNote(slug string) (*note, error) {
+	var n note
+
+	err := c.do(http.MethodGet, "/api/v1/notes/"+url.PathEscape(slug), nil, &n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (c *client) listNotes(query url.Values) (*notePage, error) {
+	var page notePage
+
+	err := c.do(http.MethodGet, "/api/v1/notes?"+query.Encode(), nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *client) searchNotes(query url.Values) (*notePage, error) {
+	var page notePage
+
+	err := c.do(http.MethodGet, "/api/v1/search?"+query.Encode(), nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *client) deleteNote(slug string) error {
+	return c.do(http.MethodDelete, "/api/v1/notes/"+url.PathEscape(slug), nil, nil)
+}
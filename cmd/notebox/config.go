@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Settings of the client, stored as JSON in the file returned by `configPath`.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	// Skips the verification of the certificate of the server, which is self-signed in development.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// Returns the path of the configuration file, which can be overridden through the `NOTEBOX_CONFIG`
+// environment variable.
+func configPath() (string, error) {
+	if path := os.Getenv("NOTEBOX_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "notebox", "config.json"), nil
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New("not configured, run `notebox config -server URL -token TOKEN` first")
+		}
+		return nil, err
+	}
+
+	var cfg config
+
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// Writes the configuration to the file, which is only readable by the current user since it holds
+// the token.
+func saveConfig(path string, cfg *config) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
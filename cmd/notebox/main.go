@@ -0,0 +1,338 @@
+// Command notebox is a client for the API of a notebox server, mostly meant to turn the output of
+// other commands into notes:
+//
+//	go test ./... 2>&1 | notebox create -t "Test run"
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage: notebox <command> [flags] [arguments]
+
+Commands:
+  config -server URL -token TOKEN   store the server and API token to use
+  create -t TITLE < FILE            create a note from the standard input
+  get SLUG                          print the content of a note
+  list                              list public notes
+  search QUERY                      search public notes
+  delete SLUG                       move one of your notes to the trash
+
+Run 'notebox <command> -h' for the flags of a command.
+`
+
+// Reported when the arguments are invalid, after the usage was already printed by the flag set.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "notebox:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+
+	cfgPath, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	cmd, args := args[0], args[1:]
+
+	if cmd == "config" {
+		return runConfig(cfgPath, args, stderr)
+	}
+
+	var runCmd func(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+	switch cmd {
+	case "create":
+		runCmd = runCreate
+	case "get":
+		runCmd = runGet
+	case "list":
+		runCmd = runList
+	case "search":
+		runCmd = runSearch
+	case "delete":
+		runCmd = runDelete
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprintf(stderr, "notebox: unknown command %q\n\n%s", cmd, usage)
+		return errUsage
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	return runCmd(newClient(cfg), args, stdin, stdout, stderr)
+}
+
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: notebox %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// Parses the flags, making sure exactly `n` arguments remain after them, unless `n` is negative.
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if n >= 0 && fs.NArg() != n {
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+func runConfig(path string, args []string, stderr io.Writer) error {
+	fs := newFlagSet("config", "-server URL -token TOKEN", stderr)
+	server := fs.String("server", "", "URL of the server, such as https://localhost:4000")
+	token := fs.String("token", "", "API token created from the account page")
+	insecure := fs.Bool("insecure", false, "Skip the verification of the certificate of the server")
+
+	err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	if *server == "" || *token == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	u, err := url.Parse(*server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid server URL %q", *server)
+	}
+
+	err = saveConfig(path, &config{Server: *server, Token: *token, Insecure: *insecure})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stderr, "Configuration saved to", path)
+
+	return nil
+}
+
+func runCreate(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("create", "-t TITLE < FILE", stderr)
+
+	var input noteInput
+	var tags string
+
+	fs.StringVar(&input.Title, "t", "", "Title of the note")
+	fs.StringVar(&input.Format, "format", "", "Format of the note, either plain or markdown")
+	fs.StringVar(&input.Language, "lang", "", "Language used to highlight the note, detected if empty")
+	fs.StringVar(&tags, "tags", "", "Comma-separated list of tags")
+	fs.StringVar(&input.Visibility, "visibility", "", "Either public, unlisted or private")
+	fs.StringVar(&input.Expires, "expires", "", "Either 1h, 1d, 7d, 30d, 365d or never")
+	fs.StringVar(&input.Password, "password", "", "Password required to read the note")
+	fs.BoolVar(&input.Burn, "burn", false, "Destroy the note once it's read")
+
+	err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	// Reading from a terminal would wait for the user to type the note, which is rarely intended.
+	if f, ok := stdin.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return errors.New("the content of the note must be piped through the standard input")
+		}
+	}
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+
+	input.Content = string(content)
+	if tags != "" {
+		input.Tags = strings.Split(tags, ",")
+	}
+
+	n, err := c.createNote(input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, c.server+n.URL)
+
+	return nil
+}
+
+// Accepts either the slug of a note or its URL.
+func slugArg(arg string) string {
+	return path.Base(strings.TrimSuffix(arg, "/"))
+}
+
+func runGet(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("get", "SLUG", stderr)
+
+	err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.getNote(slugArg(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case n.Encrypted:
+		return fmt.Errorf("the note is encrypted, open %s%s along with its key in a browser", c.server, n.URL)
+	case n.Content != nil:
+		fmt.Fprint(stdout, *n.Content)
+		// Keeps the prompt of the shell on its own line.
+		if !strings.HasSuffix(*n.Content, "\n") {
+			fmt.Fprintln(stdout)
+		}
+		return nil
+	case n.Burn:
+		return fmt.Errorf("the note is destroyed once read, open %s%s in a browser", c.server, n.URL)
+	default:
+		return fmt.Errorf("the note is password-protected, open %s%s in a browser", c.server, n.URL)
+	}
+}
+
+func runList(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("list", "", stderr)
+	limit := fs.Int("limit", 20, "Maximum number of notes listed")
+	before := fs.Int("before", 0, "List the notes older than the given position")
+	after := fs.Int("after", 0, "List the notes newer than the given position")
+
+	err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(*limit))
+	if *before > 0 {
+		query.Set("before", strconv.Itoa(*before))
+	}
+	if *after > 0 {
+		query.Set("after", strconv.Itoa(*after))
+	}
+
+	page, err := c.listNotes(query)
+	if err != nil {
+		return err
+	}
+
+	printNotes(stdout, page.Notes)
+
+	if q, ok := pageQuery(page.Next); ok {
+		fmt.Fprintf(stderr, "More notes: notebox list -limit %d -before %s\n", *limit, q.Get("before"))
+	}
+
+	return nil
+}
+
+func runSearch(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("search", "QUERY", stderr)
+	page := fs.Int("page", 1, "Page of results")
+
+	err := parseFlags(fs, args, -1)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	query := url.Values{}
+	query.Set("q", strings.Join(fs.Args(), " "))
+	query.Set("page", strconv.Itoa(*page))
+
+	results, err := c.searchNotes(query)
+	if err != nil {
+		return err
+	}
+
+	printNotes(stdout, results.Notes)
+
+	if q, ok := pageQuery(results.Next); ok {
+		fmt.Fprintf(stderr, "More results: notebox search -page %s %s\n", q.Get("page"), strings.Join(fs.Args(), " "))
+	}
+
+	return nil
+}
+
+func runDelete(c *client, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("delete", "SLUG", stderr)
+
+	err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	err = c.deleteNote(slugArg(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stderr, "Note moved to the trash")
+
+	return nil
+}
+
+// Prints the notes as a table, one note per line.
+func printNotes(w io.Writer, notes []note) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	for _, n := range notes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", n.Slug, n.Created.Format("2006-01-02"), n.Author, n.Title)
+	}
+
+	tw.Flush()
+}
+
+// Returns the query of the URL of another page, if there's one.
+func pageQuery(pageURL string) (url.Values, bool) {
+	if pageURL == "" {
+		return nil, false
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, false
+	}
+
+	return u.Query(), true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+// Serves a fake version of the API, only accepting requests authenticated through `nb_token`.
+func newTestAPI(t *testing.T) *httptest.Server {
+	content := "An old silent pond"
+
+	pond := note{
+		Slug:    "note000001",
+		URL:     "/n/note000001",
+		Author:  "Alice",
+		Title:   "An old silent pond",
+		Content: &content,
+	}
+
+	locked := pond
+	locked.Slug, locked.URL, locked.Content, locked.Protected = "note000008", "/n/note000008", nil, true
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/notes", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(notePage{Notes: []note{pond}, Next: "/api/v1/notes?before=1&limit=20"})
+		case http.MethodPost:
+			var input noteInput
+			json.NewDecoder(r.Body).Decode(&input)
+
+			if input.Title == "" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"errors":{"title":"Field cannot be blank"}}`))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(note{Slug: "note000002", URL: "/n/note000002", Title: input.Title, Content: &input.Content})
+		}
+	})
+	mux.HandleFunc("/api/v1/notes/note000001", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(pond)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("/api/v1/notes/note000008", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(locked)
+	})
+	mux.HandleFunc("/api/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(notePage{Notes: []note{pond}})
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Header.Get("Authorization") != "Bearer nb_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid or missing authentication token"}`))
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestRun(t *testing.T) {
+	ts := newTestAPI(t)
+
+	t.Setenv("NOTEBOX_CONFIG", filepath.Join(t.TempDir(), "notebox", "config.json"))
+
+	var stdout, stderr bytes.Buffer
+
+	err := run([]string{"config", "-server", ts.URL, "-token", "nb_token"}, nil, &stdout, &stderr)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantOut    string
+		wantErr    string
+		wantStderr string
+	}{
+		{
+			name:    "Create",
+			args:    []string{"create", "-t", "Test run", "-tags", "ci,go"},
+			stdin:   "ok  \tgithub.com/gustavodiasag/notebox\n",
+			wantOut: ts.URL + "/n/note000002\n",
+		},
+		{
+			name:    "Create without title",
+			args:    []string{"create"},
+			stdin:   "ok",
+			wantErr: "title: Field cannot be blank",
+		},
+		{
+			name:    "Get",
+			args:    []string{"get", "note000001"},
+			wantOut: "An old silent pond\n",
+		},
+		{
+			name:    "Get by URL",
+			args:    []string{"get", ts.URL + "/n/note000001"},
+			wantOut: "An old silent pond\n",
+		},
+		{
+			name:    "Get protected note",
+			args:    []string{"get", "note000008"},
+			wantErr: "the note is password-protected",
+		},
+		{
+			name:       "List",
+			args:       []string{"list"},
+			wantOut:    "note000001",
+			wantStderr: "notebox list -limit 20 -before 1",
+		},
+		{
+			name:    "Search",
+			args:    []string{"search", "silent", "pond"},
+			wantOut: "An old silent pond",
+		},
+		{
+			name:       "Delete",
+			args:       []string{"delete", "note000001"},
+			wantStderr: "Note moved to the trash",
+		},
+		{
+			name:    "Missing argument",
+			args:    []string{"get"},
+			wantErr: errUsage.Error(),
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"edit"},
+			wantErr: errUsage.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("got: nil; want error containing: %q", tt.wantErr)
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.StringContains(t, stdout.String(), tt.wantOut)
+			assert.StringContains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+
+	t.Run("Invalid token", func(t *testing.T) {
+		err := run([]string{"config", "-server", ts.URL, "-token", "nb_wrong"}, nil, &stdout, &stderr)
+		assert.NilError(t, err)
+
+		err = run([]string{"list"}, nil, &stdout, &stderr)
+		if err == nil {
+			t.Fatal("got: nil; want authentication error")
+		}
+		assert.StringContains(t, err.Error(), "invalid or missing authentication token")
+	})
+}
+
+func TestRunWithoutConfig(t *testing.T) {
+	t.Setenv("NOTEBOX_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	var stdout, stderr bytes.Buffer
+
+	err := run([]string{"list"}, nil, &stdout, &stderr)
+	if err == nil {
+		t.Fatal("got: nil; want configuration error")
+	}
+	assert.StringContains(t, err.Error(), "not configured")
+}
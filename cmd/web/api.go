@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/models"
+	"github.com/gustavodiasag/notebox/internal/validator"
+)
+
+// Representation of a note in the responses of the API. Notes are identified by their slugs, the
+// same as in their links, although the cursors of paginated lists are still their sequential IDs.
+// `Content` is left out when it can't be read by the client, as for locked and burn-after-reading
+// notes, and `Expires` is null for notes which never expire.
+type apiNote struct {
+	Slug       string     `json:"slug"`
+	URL        string     `json:"url"`
+	Author     string     `json:"author"`
+	Title      string     `json:"title"`
+	Content    *string    `json:"content,omitempty"`
+	Format     string     `json:"format"`
+	Language   string     `json:"language"`
+	Visibility string     `json:"visibility"`
+	Tags       []string   `json:"tags"`
+	Burn       bool       `json:"burn"`
+	Protected  bool       `json:"protected"`
+	Encrypted  bool       `json:"encrypted"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires"`
+}
+
+// Page of notes, linking to the previous and next pages when there are any.
+type apiNotePage struct {
+	Notes []apiNote `json:"notes"`
+	Prev  string    `json:"prev,omitempty"`
+	Next  string    `json:"next,omitempty"`
+}
+
+type apiAccount struct {
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+}
+
+func (app *application) newAPINote(r *http.Request, note *models.Note) apiNote {
+	n := apiNote{
+		Slug:       note.Slug,
+		URL:        "/n/" + note.Slug,
+		Author:     note.Author,
+		Title:      note.Title,
+		Format:     note.Format,
+		Language:   note.Language,
+		Visibility: note.Visibility,
+		Tags:       note.Tags,
+		Burn:       note.Burn,
+		Protected:  note.Protected,
+		Encrypted:  note.Encrypted,
+		Created:    note.Created,
+	}
+
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+	// Burn-after-reading notes can only be read, and destroyed, through the web interface.
+	if !note.Burn && app.isUnlocked(r, note) {
+		n.Content = &note.Content
+	}
+	if !note.Expires.IsZero() {
+		n.Expires = &note.Expires
+	}
+
+	return n
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiError(w, http.StatusNotFound, "the requested resource could not be found")
+}
+
+func (app *application) invalidTokenResponse(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiError(w, http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, err.Error())
+	app.apiError(w, http.StatusInternalServerError, "the server encountered a problem and could not process the request")
+}
+
+// Same as `noteBySlug`, although sending the corresponding JSON error response and returning
+// `false` when the note can't be retrieved.
+func (app *application) apiViewableNote(w http.ResponseWriter, r *http.Request) (*models.Note, bool) {
+	note, err := app.noteBySlug(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	return note, true
+}
+
+// Same as `apiViewableNote`, as long as the note belongs to the authenticated user.
+func (app *application) apiOwnedNote(w http.ResponseWriter, r *http.Request) (*models.Note, bool) {
+	note, ok := app.apiViewableNote(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if note.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, http.StatusForbidden, "the note belongs to another user")
+		return nil, false
+	}
+
+	return note, true
+}
+
+func (app *application) apiNoteList(w http.ResponseWriter, r *http.Request) {
+	form := noteListForm{
+		Limit: 20,
+	}
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "query contains invalid parameters")
+		return
+	}
+
+	checkNoteListForm(&form)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	notes, prev, next, err := app.listNotes(form)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	page := apiNotePage{
+		Notes: []apiNote{},
+	}
+
+	for _, note := range notes {
+		page.Notes = append(page.Notes, app.newAPINote(r, note))
+	}
+	if prev != "" {
+		page.Prev = "/api/v1/notes?" + prev
+	}
+	if next != "" {
+		page.Next = "/api/v1/notes?" + next
+	}
+
+	app.writeJSON(w, http.StatusOK, page)
+}
+
+func (app *application) apiNoteSearch(w http.ResponseWriter, r *http.Request) {
+	form := apiSearchForm{
+		searchForm: searchForm{Page: 1},
+	}
+
+	err := app.formDecoder.Decode(&form.searchForm, r.URL.Query())
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "query contains invalid parameters")
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Query), "q", "Field cannot be blank")
+	form.CheckField(validator.InRange(form.Page, 1, maxSearchPage), "page", "Field must be between 1 and 1000")
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	notes, err := app.notes.Search(form.Query, form.Page)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	page := apiNotePage{
+		Notes: []apiNote{},
+	}
+
+	for _, note := range notes {
+		page.Notes = append(page.Notes, app.newAPINote(r, note))
+	}
+
+	pageURL := func(page int) string {
+		v := url.Values{}
+		v.Set("q", form.Query)
+		v.Set("page", strconv.Itoa(page))
+		return "/api/v1/search?" + v.Encode()
+	}
+
+	if form.Page > 1 {
+		page.Prev = pageURL(form.Page - 1)
+	}
+	// A full page indicates there may be more results.
+	if len(notes) == models.SearchPageSize {
+		page.Next = pageURL(form.Page + 1)
+	}
+
+	app.writeJSON(w, http.StatusOK, page)
+}
+
+func (app *application) apiNoteView(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.apiViewableNote(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, app.newAPINote(r, note))
+}
+
+// Same as `searchForm`, along with the validation which the web interface doesn't need, since it
+// shows the search form when the query is blank.
+type apiSearchForm struct {
+	searchForm
+	validator.Validator
+}
+
+// Fields accepted when creating a note, any blank one taking the same default as in the web form.
+type apiNoteCreateInput struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Format     string   `json:"format"`
+	Language   string   `json:"language"`
+	Tags       []string `json:"tags"`
+	Visibility string   `json:"visibility"`
+	Burn       bool     `json:"burn"`
+	Password   string   `json:"password"`
+	Expires    string   `json:"expires"`
+	ExpiresAt  string   `json:"expires_at"`
+}
+
+func (app *application) apiNoteCreate(w http.ResponseWriter, r *http.Request) {
+	input := apiNoteCreateInput{
+		Format:     models.FormatPlain,
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := noteCreateForm{
+		Title:      input.Title,
+		Content:    input.Content,
+		Format:     input.Format,
+		Language:   input.Language,
+		Tags:       strings.Join(input.Tags, ","),
+		Visibility: input.Visibility,
+		Burn:       input.Burn,
+		Password:   input.Password,
+		Expires:    input.Expires,
+		ExpiresAt:  input.ExpiresAt,
+	}
+
+	note := checkNoteCreateForm(&form)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	note.UserID = app.authenticatedUserID(r)
+
+	id, err := app.notes.Insert(note, form.Password)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// Read back, so that the response describes the note exactly as it was stored.
+	note, err = app.notes.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/notes/"+note.Slug)
+	app.writeJSON(w, http.StatusCreated, app.newAPINote(r, note))
+}
+
+// Fields accepted when updating a note, any missing one being left unchanged.
+type apiNoteUpdateInput struct {
+	Title      *string   `json:"title"`
+	Content    *string   `json:"content"`
+	Format     *string   `json:"format"`
+	Language   *string   `json:"language"`
+	Tags       *[]string `json:"tags"`
+	Visibility *string   `json:"visibility"`
+}
+
+func (app *application) apiNoteUpdate(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.apiOwnedNote(w, r)
+	if !ok {
+		return
+	}
+	// Encrypted notes can't be edited, since their key is never known by the server.
+	if note.Encrypted {
+		app.apiError(w, http.StatusBadRequest, "encrypted notes can't be updated")
+		return
+	}
+
+	var input apiNoteUpdateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := noteEditForm{
+		Title:      note.Title,
+		Content:    note.Content,
+		Format:     note.Format,
+		Language:   note.Language,
+		Tags:       strings.Join(note.Tags, ","),
+		Visibility: note.Visibility,
+	}
+
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	if input.Content != nil {
+		form.Content = *input.Content
+	}
+	if input.Format != nil {
+		form.Format = *input.Format
+	}
+	if input.Language != nil {
+		form.Language = *input.Language
+	}
+	if input.Tags != nil {
+		form.Tags = strings.Join(*input.Tags, ",")
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+
+	checkNoteEditForm(&form, note)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	err = app.notes.Update(note)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, app.newAPINote(r, note))
+}
+
+// Moves the note to the trash, just like its deletion through the web interface.
+func (app *application) apiNoteDelete(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.apiOwnedNote(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.notes.Delete(note.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiAccountView(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, apiAccount{
+		Name:    user.Name,
+		Email:   user.Email,
+		Created: user.Created,
+	})
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestAPINoteList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/api/v1/notes",
+			wantCode: http.StatusOK,
+			wantBody: `"slug":"note000001"`,
+		},
+		{
+			name:     "Empty page",
+			urlPath:  "/api/v1/notes?after=1",
+			wantCode: http.StatusOK,
+			wantBody: `"notes":[]`,
+		},
+		{
+			name:     "Invalid limit",
+			urlPath:  "/api/v1/notes?limit=1000",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"limit":`,
+		},
+		{
+			name:     "Malformed query",
+			urlPath:  "/api/v1/notes?before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPINoteSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Matching query",
+			urlPath:  "/api/v1/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: `"slug":"note000001"`,
+		},
+		{
+			name:     "No matches",
+			urlPath:  "/api/v1/search?q=firefly",
+			wantCode: http.StatusOK,
+			wantBody: `"notes":[]`,
+		},
+		{
+			name:     "Second page",
+			urlPath:  "/api/v1/search?q=pond&page=2",
+			wantCode: http.StatusOK,
+			wantBody: `"prev":"/api/v1/search?page=1\u0026q=pond"`,
+		},
+		{
+			name:     "Protected note",
+			urlPath:  "/api/v1/search?q=battery",
+			wantCode: http.StatusOK,
+			wantBody: `"notes":[]`,
+		},
+		{
+			name:     "Blank query",
+			urlPath:  "/api/v1/search?q=",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"q":"Field cannot be blank"`,
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/api/v1/search?q=pond&page=0",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"page":`,
+		},
+		{
+			name:     "Page too large",
+			urlPath:  "/api/v1/search?q=pond&page=9223372036854775807",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"page":"Field must be between 1 and 1000"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPINoteView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    string
+		wantContent bool
+	}{
+		{
+			name:        "Valid slug",
+			urlPath:     "/api/v1/notes/note000001",
+			wantCode:    http.StatusOK,
+			wantBody:    `"title":"An old silent pond"`,
+			wantContent: true,
+		},
+		{
+			name:     "Private note",
+			urlPath:  "/api/v1/notes/note000006",
+			wantCode: http.StatusNotFound,
+			wantBody: `"error":`,
+		},
+		{
+			name:     "Burn note",
+			urlPath:  "/api/v1/notes/note000007",
+			wantCode: http.StatusOK,
+			wantBody: `"burn":true`,
+		},
+		{
+			name:     "Protected note",
+			urlPath:  "/api/v1/notes/note000008",
+			wantCode: http.StatusOK,
+			wantBody: `"protected":true`,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/api/v1/notes/note000002",
+			wantCode: http.StatusNotFound,
+			wantBody: `"error":`,
+		},
+		{
+			name:     "Invalid slug",
+			urlPath:  "/api/v1/notes/foo",
+			wantCode: http.StatusNotFound,
+			wantBody: `"error":`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+			assert.Equal(t, strings.Contains(body, `"content":`), tt.wantContent)
+		})
+	}
+
+	t.Run("Unknown route", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/api/v1/foo")
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	})
+}
+
+func TestAPINoteCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, body := ts.postJSON(t, "/api/v1/notes", `{"title": "Haiku", "content": "An old silent pond"}`, validCSRFToken)
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.StringContains(t, body, `"error":`)
+	})
+
+	ts.login(t)
+
+	_, _, body = ts.get(t, "/note/create")
+	validCSRFToken = extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		body         string
+		csrfToken    string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:         "Valid submission",
+			body:         `{"title": "Haiku", "content": "An old silent pond", "tags": ["haiku", "nature"]}`,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusCreated,
+			wantBody:     `"tags":["haiku","nature"]`,
+			wantLocation: "/api/v1/notes/note000011",
+		},
+		{
+			name:      "Empty title",
+			body:      `{"title": "", "content": "An old silent pond"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  `"title":"Field cannot be blank"`,
+		},
+		{
+			name:      "Invalid visibility",
+			body:      `{"title": "Haiku", "content": "An old silent pond", "visibility": "secret"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  `"visibility":`,
+		},
+		{
+			name:      "Unknown field",
+			body:      `{"title": "Haiku", "content": "An old silent pond", "author": "Bob"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusBadRequest,
+			wantBody:  `"error":"body contains unknown field \"author\""`,
+		},
+		{
+			name:      "Invalid CSRF token",
+			body:      `{"title": "Haiku", "content": "An old silent pond"}`,
+			csrfToken: "invalidToken",
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.postJSON(t, "/api/v1/notes", tt.body, tt.csrfToken)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPINoteUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Partial update",
+			urlPath:  "/api/v1/notes/note000001",
+			body:     `{"title": "Haiku"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"title":"Haiku"`,
+		},
+		{
+			name:     "Empty content",
+			urlPath:  "/api/v1/notes/note000001",
+			body:     `{"content": ""}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"content":"Field cannot be blank"`,
+		},
+		{
+			name:     "Encrypted note",
+			urlPath:  "/api/v1/notes/note000009",
+			body:     `{"title": "Haiku"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Note of another user",
+			urlPath:  "/api/v1/notes/note000003",
+			body:     `{"title": "Haiku"}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/api/v1/notes/note000002",
+			body:     `{"title": "Haiku"}`,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.sendJSON(t, http.MethodPatch, tt.urlPath, tt.body, validCSRFToken)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPINoteDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Own note",
+			urlPath:  "/api/v1/notes/note000001",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Note of another user",
+			urlPath:  "/api/v1/notes/note000003",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/api/v1/notes/note000002",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.sendJSON(t, http.MethodDelete, tt.urlPath, "", validCSRFToken)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAPIAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/api/v1/account")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/api/v1/account")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"email":"alice@example.com"`)
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		body     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Read with read token",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/account",
+			token:    "nb_readtoken",
+			wantCode: http.StatusOK,
+			wantBody: `"email":"alice@example.com"`,
+		},
+		{
+			name:     "Private note of another user",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/notes/note000006",
+			token:    "nb_readtoken",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Write with read token",
+			method:   http.MethodPost,
+			urlPath:  "/api/v1/notes",
+			body:     `{"title": "Haiku", "content": "An old silent pond"}`,
+			token:    "nb_readtoken",
+			wantCode: http.StatusForbidden,
+			wantBody: `"error":`,
+		},
+		{
+			name:     "Write with write token",
+			method:   http.MethodPost,
+			urlPath:  "/api/v1/notes",
+			body:     `{"title": "Haiku", "content": "An old silent pond"}`,
+			token:    "nb_writetoken",
+			wantCode: http.StatusCreated,
+			wantBody: `"author":"Alice"`,
+		},
+		{
+			name:     "Delete with write token",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/notes/note000001",
+			token:    "nb_writetoken",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Invalid token",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/notes",
+			token:    "nb_wrongtoken",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `"error":`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.sendWithToken(t, tt.method, tt.urlPath, tt.body, tt.token)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Invalid scheme", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/account", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Basic YWxpY2U6cGFzcw==")
+
+		code, headers, _ := ts.do(t, req)
+
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, headers.Get("WWW-Authenticate"), "Bearer")
+	})
+}
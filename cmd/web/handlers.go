@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gustavodiasag/notebox/internal/diff"
+	"github.com/gustavodiasag/notebox/internal/markup"
 	"github.com/gustavodiasag/notebox/internal/models"
 	"github.com/gustavodiasag/notebox/internal/validator"
 	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/otp/totp"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -67,7 +74,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	token, err := app.users.Insert(form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
@@ -82,14 +89,113 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sendVerificationEmail(form.Name, form.Email, token)
+
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Check your email to verify your address before logging in.")
 
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-type userLoginForm struct {
+// Sends the link verifying the email address of the user in the background, so that the response
+// doesn't wait for the mail server.
+func (app *application) sendVerificationEmail(name, email, token string) {
+	app.background(func() {
+		data := map[string]any{
+			"Name": name,
+			"URL":  app.baseURL + "/user/verify/" + token,
+		}
+
+		err := app.mailer.Send(email, "user_verification.tmpl", data)
+		if err != nil {
+			app.errorLog.Printf("sending verification email: %v", err)
+		}
+	})
+}
+
+type userVerifyForm struct {
 	Email               string `form:"email"`
-	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userVerifyForm{}
+
+	app.render(w, http.StatusOK, "verify.tmpl.html", data)
+}
+
+func (app *application) userVerifyToken(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	err := app.users.Verify(params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form := userVerifyForm{}
+			form.AddNonFieldError("This verification link is invalid or has expired. Request a new one below.")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusNotFound, "verify.tmpl.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address was verified. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// Sends a new verification link to the address. Whether an unverified account uses it isn't
+// disclosed, the response being the same in any case.
+func (app *application) userVerifyPost(w http.ResponseWriter, r *http.Request) {
+	var form userVerifyForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "Field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Invalid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "verify.tmpl.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if user != nil && !user.Verified {
+		token, err := app.users.NewVerificationToken(user.ID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		// The account may have been verified in the meantime.
+		if err == nil {
+			app.sendVerificationEmail(user.Name, user.Email, token)
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an unverified account uses this address, a new verification link was sent to it.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+type userLoginForm struct {
+	Email    string `form:"email"`
+	Password string `form:"password"`
+	// Whether the credentials were valid although the email address wasn't verified yet.
+	Unverified          bool `form:"-"`
 	validator.Validator `form:"-"`
 }
 
@@ -119,29 +225,97 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Counted before the password is checked, so that throttled attempts don't cost a bcrypt
+	// comparison, and concurrent ones can't all be made before any of them fails.
+	accountKey := strings.ToLower(form.Email)
+
+	if wait := app.attemptLogin(r, accountKey); wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts, please try again in %s", fmtWait(wait)))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login.tmpl.html", data)
+		return
+	}
+
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			app.failLogin(r, accountKey, form.Email)
 			form.AddNonFieldError("Email or password is incorrect")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
-		} else {
+		case errors.Is(err, models.ErrUnverifiedAccount):
+			app.releaseLogin(r, accountKey)
+			form.AddNonFieldError("Your email address isn't verified yet. Follow the link sent to it to log in.")
+			form.Unverified = true
+		default:
 			app.serverError(w, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
-	// Changes the session ID given the change of privilege level.
+	enabled, err := app.totp.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The password was right, although the user may still have to enter the code of their app.
+	app.releaseLogin(r, accountKey)
+
+	if !enabled {
+		app.accountBackoff.Reset(accountKey)
+		app.logIn(w, r, id)
+		return
+	}
+
+	// The user is only logged in once the code of their authenticator app is checked.
+	oldToken := app.sessionManager.Token(r.Context())
+
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.sessionManager.Put(r.Context(), "totpUserID", id)
+	app.sessionManager.Put(r.Context(), "totpDeadline", time.Now().Add(totpLoginDuration).Unix())
+	app.sessionManager.Remove(r.Context(), "totpAttempts")
+
+	err = app.indexSession(r, oldToken, id, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+}
+
+// Marks the session as authenticated by the user, redirecting them to the page they were trying to
+// access before logging in, if any.
+func (app *application) logIn(w http.ResponseWriter, r *http.Request, id int) {
+	oldToken := app.sessionManager.Token(r.Context())
+
+	// Changes the session ID given the change of privilege level.
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
 
+	err = app.indexSession(r, oldToken, id, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	path := app.sessionManager.PopString(r.Context(), "redirectPath")
 	if path != "" {
 		http.Redirect(w, r, path, http.StatusSeeOther)
@@ -151,170 +325,1493 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/note/create", http.StatusSeeOther)
 }
 
-func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
-	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+const (
+	// Time given to users to enter the code of their authenticator app once their password is
+	// checked.
+	totpLoginDuration = 5 * time.Minute
+	// Number of wrong codes after which users have to enter their password again.
+	totpLoginAttempts = 5
+)
 
-	user, err := app.users.Get(userID)
-	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-		} else {
-			app.serverError(w, err)
-		}
-		return
+type userTOTPForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// Returns the ID of the user whose password was checked, as long as they're still given time to
+// enter the code of their authenticator app, or zero otherwise. The pending login is revoked along
+// with the other sessions of the user, for example when their password is reset.
+func (app *application) totpUserID(r *http.Request) (int, error) {
+	if time.Now().Unix() >= app.sessionManager.GetInt64(r.Context(), "totpDeadline") {
+		return 0, nil
 	}
 
-	data := app.newTemplateData(r)
-	data.User = user
+	id := app.sessionManager.GetInt(r.Context(), "totpUserID")
+	if id == 0 {
+		return 0, nil
+	}
+
+	ok, err := app.checkSession(r, id)
+	if err != nil || !ok {
+		return 0, err
+	}
 
-	app.render(w, http.StatusOK, "account.tmpl.html", data)
+	return id, nil
 }
 
-type passwordUpdateForm struct {
-	Current             string `form:"current"`
-	New                 string `form:"new"`
-	Confirm             string `form:"confirm"`
-	validator.Validator `form:"-"`
+// Forgets the user whose password was checked, who then has to log in from scratch.
+func (app *application) clearTOTPLogin(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), "totpUserID")
+	app.sessionManager.Remove(r.Context(), "totpDeadline")
+	app.sessionManager.Remove(r.Context(), "totpAttempts")
 }
 
-func (app *application) passwordUpdate(w http.ResponseWriter, r *http.Request) {
+func (app *application) userLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	id, err := app.totpUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if id == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = passwordUpdateForm{}
+	data.Form = userTOTPForm{}
 
-	app.render(w, http.StatusOK, "password.tmpl.html", data)
+	app.render(w, http.StatusOK, "totp.tmpl.html", data)
 }
 
-func (app *application) passwordUpdatePost(w http.ResponseWriter, r *http.Request) {
-	var form passwordUpdateForm
+// Completes the login of users with two-factor authentication, accepting either the code of their
+// authenticator app or one of their recovery codes.
+func (app *application) userLoginTOTPPost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.totpUserID(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	err := app.decodePostForm(r, &form)
+	if id == 0 {
+		app.clearTOTPLogin(r)
+		app.sessionManager.Put(r.Context(), "flash", "Your login expired. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userTOTPForm
+
+	err = app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Current), "current", "Field cannot be blank")
-	form.CheckField(validator.NotBlank(form.New), "new", "Field cannot be blank")
-	form.CheckField(validator.MinChars(form.New, 8), "new", "Field must be at least 8 characters long")
-	form.CheckField(validator.NotBlank(form.Confirm), "confirm", "Field cannot be blank")
-	form.CheckField(form.New == form.Confirm, "confirm", "Passwords do not match")
+	form.CheckField(validator.NotBlank(form.Code), "code", "Field cannot be blank")
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-
-		app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "totp.tmpl.html", data)
 		return
 	}
 
-	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
-
-	err = app.users.UpdatePassword(userID, form.Current, form.New)
+	user, err := app.users.Get(id)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddFieldError("current", "Current password is incorrect")
+		app.serverError(w, err)
+		return
+	}
 
-			data := app.newTemplateData(r)
-			data.Form = form
+	// Wrong codes count against the account just like wrong passwords.
+	accountKey := strings.ToLower(user.Email)
 
-			app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
-		} else {
-			app.serverError(w, err)
-		}
+	if wait := app.attemptLogin(r, accountKey); wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts, please try again in %s", fmtWait(wait)))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "totp.tmpl.html", data)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+	recovery := !validator.Matches(form.Code, validator.TOTPCodeRX)
 
-	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
-}
+	if recovery {
+		err = app.totp.UseRecoveryCode(id, form.Code)
+	} else {
+		err = app.totp.Validate(id, form.Code)
+	}
 
-func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			app.serverError(w, err)
+			return
+		}
+
+		app.failLogin(r, accountKey, user.Email)
+
+		attempts := app.sessionManager.GetInt(r.Context(), "totpAttempts") + 1
+		if attempts >= totpLoginAttempts {
+			app.clearTOTPLogin(r)
+			app.sessionManager.Put(r.Context(), "flash", "Too many invalid codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "totpAttempts", attempts)
+
+		form.AddNonFieldError("Invalid authentication code")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "totp.tmpl.html", data)
 		return
 	}
 
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
-	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully")
+	app.clearTOTPLogin(r)
+	app.releaseLogin(r, accountKey)
+	app.accountBackoff.Reset(accountKey)
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	if recovery {
+		app.sessionManager.Put(r.Context(), "flash", "You logged in with a recovery code, which can't be used again.")
+	}
+
+	app.logIn(w, r, id)
 }
 
-func (app *application) noteView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	app.renderAccount(w, r, http.StatusOK, tokenCreateForm{Scope: models.ScopeRead})
+}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
-		return
-	}
+// Renders the account page of the authenticated user, along with their API tokens, using `form`
+// for the creation of new ones.
+func (app *application) renderAccount(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm) {
+	userID := app.authenticatedUserID(r)
 
-	note, err := app.notes.Get(id)
+	user, err := app.users.Get(userID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
+	tokens, err := app.tokens.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	totpEnabled, err := app.totp.Enabled(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	sessions, err := app.userSessions(r, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Note = note
+	data.User = user
+	data.Tokens = tokens
+	// Tokens are only shown once, right after being created.
+	data.NewToken = app.sessionManager.PopString(r.Context(), "newToken")
+	data.TOTPEnabled = totpEnabled
+	data.Sessions = sessions
+	// So are recovery codes, right after two-factor authentication is enabled.
+	if codes := app.sessionManager.PopString(r.Context(), "recoveryCodes"); codes != "" {
+		data.RecoveryCodes = strings.Split(codes, "\n")
+		data.RecoveryCodesURL = template.URL("data:text/plain;charset=utf-8," + url.PathEscape(codes+"\n"))
+	}
+	data.Form = form
 
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	app.render(w, status, "account.tmpl.html", data)
 }
 
-type noteCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
-	// Ignores field while encoding.
+type totpSetupForm struct {
+	Code string `form:"code"`
+	// Secret of the app being set up, shown for apps which can't scan the QR code.
+	Secret              string `form:"-"`
 	validator.Validator `form:"-"`
 }
 
-func (app *application) noteCreate(w http.ResponseWriter, r *http.Request) {
-	data := app.newTemplateData(r)
-	// Sets any default or initial values for the form.
-	data.Form = noteCreateForm{
-		Expires: 365,
+// Renders the page setting up an authenticator app with the secret which is pending confirmation.
+func (app *application) renderTOTPSetup(w http.ResponseWriter, r *http.Request, status int, form totpSetupForm) {
+	var err error
+
+	form.Secret, err = app.totp.Pending(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/totp/setup", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl.html", data)
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	app.render(w, status, "totp_setup.tmpl.html", data)
 }
 
-func (app *application) noteCreatePost(w http.ResponseWriter, r *http.Request) {
-	var form noteCreateForm
+// Generates a new secret for the user to set up an authenticator app with.
+func (app *application) totpSetup(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
 
-	err := app.decodePostForm(r, &form)
+	enabled, err := app.totp.Enabled(userID)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.serverError(w, err)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "Field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "Field cannot exceed 100 characters")
-	form.CheckField(validator.NotBlank(form.Content), "content", "Field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "Field must be equal to 1, 7 or 365")
-
+	if enabled {
+		app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is already enabled.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: user.Email})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.totp.Setup(userID, key.Secret())
+	if err != nil {
+		if errors.Is(err, models.ErrNoTOTPKey) {
+			app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication isn't available on this server.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.renderTOTPSetup(w, r, http.StatusOK, totpSetupForm{})
+}
+
+// Serves the QR code of the secret which is pending confirmation. It's served from its own route,
+// rather than embedded in the page as a data URL, so that the content security policy can keep
+// images restricted to the application's origin.
+func (app *application) totpSetupQRCode(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	secret, err := app.totp.Pending(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	png, err := totpQRCode(user.Email, secret)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+// Enables two-factor authentication once the user enters a code of the app they set up.
+func (app *application) totpSetupPost(w http.ResponseWriter, r *http.Request) {
+	var form totpSetupForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.Matches(form.Code, validator.TOTPCodeRX), "code", "Field must be the 6-digit code shown by the app")
+
+	if !form.Valid() {
+		app.renderTOTPSetup(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	codes, err := app.totp.Enable(app.authenticatedUserID(r), form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			form.AddFieldError("code", "Invalid code, make sure the clock of your device is correct")
+			app.renderTOTPSetup(w, r, http.StatusUnprocessableEntity, form)
+		case errors.Is(err, models.ErrNoRecord):
+			http.Redirect(w, r, "/account/totp/setup", http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "recoveryCodes", strings.Join(codes, "\n"))
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is enabled. Keep your recovery codes somewhere safe.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	Scope               string `form:"scope"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) tokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "Field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "Field cannot exceed 100 characters")
+	form.CheckField(validator.PermittedValue(form.Scope, models.ScopeRead, models.ScopeWrite), "scope", "Field must be equal to read or write")
+
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	token, err := app.tokens.Insert(app.authenticatedUserID(r), form.Name, form.Scope)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "newToken", token)
+	app.sessionManager.Put(r.Context(), "flash", "Token created! Make sure to copy it now, as it won't be shown again.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) tokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Tokens of other users are reported as missing.
+	err = app.tokens.Revoke(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) sessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	found, err := app.destroySession(r, app.authenticatedUserID(r), params.ByName("id"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !found {
+		app.notFound(w)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The session was logged out.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) sessionRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	err := app.destroyUserSessions(r, app.authenticatedUserID(r), true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You were logged out everywhere else.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+type passwordUpdateForm struct {
+	Current             string `form:"current"`
+	New                 string `form:"new"`
+	Confirm             string `form:"confirm"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) passwordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = passwordUpdateForm{}
+
+	app.render(w, http.StatusOK, "password.tmpl.html", data)
+}
+
+func (app *application) passwordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form passwordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Current), "current", "Field cannot be blank")
+	form.CheckField(validator.NotBlank(form.New), "new", "Field cannot be blank")
+	form.CheckField(validator.MinChars(form.New, 8), "new", "Field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.Confirm), "confirm", "Field cannot be blank")
+	form.CheckField(form.New == form.Confirm, "confirm", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+
+		app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err = app.users.UpdatePassword(userID, form.Current, form.New)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("current", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+
+			app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Whoever knew the previous password is logged out, except for the user who changed it.
+	err = app.destroyUserSessions(r, userID, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated! Any other session was logged out.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+type passwordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = passwordForgotForm{}
+
+	app.render(w, http.StatusOK, "forgot.tmpl.html", data)
+}
+
+// Sends a link to reset the password to the address. Whether an account uses it isn't disclosed,
+// the response being the same in any case.
+func (app *application) passwordForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form passwordForgotForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "Field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Invalid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "forgot.tmpl.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if user != nil {
+		token, err := app.users.NewPasswordResetToken(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.background(func() {
+			data := map[string]any{
+				"Name": user.Name,
+				"URL":  app.baseURL + "/user/password/reset/" + token,
+			}
+
+			err := app.mailer.Send(user.Email, "password_reset.tmpl", data)
+			if err != nil {
+				app.errorLog.Printf("sending password reset email: %v", err)
+			}
+		})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account uses this address, a link to reset its password was sent to it.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+type passwordResetForm struct {
+	New     string `form:"new"`
+	Confirm string `form:"confirm"`
+	// Token taken from the URL, which the form is submitted back to.
+	Token               string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) passwordReset(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	data := app.newTemplateData(r)
+	data.Form = passwordResetForm{Token: params.ByName("token")}
+
+	app.render(w, http.StatusOK, "reset.tmpl.html", data)
+}
+
+func (app *application) passwordResetPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	form := passwordResetForm{Token: params.ByName("token")}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.New), "new", "Field cannot be blank")
+	form.CheckField(validator.MinChars(form.New, 8), "new", "Field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.Confirm), "confirm", "Field cannot be blank")
+	form.CheckField(form.New == form.Confirm, "confirm", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reset.tmpl.html", data)
+		return
+	}
+
+	userID, err := app.users.ResetPassword(form.Token, form.New)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form := passwordForgotForm{}
+			form.AddNonFieldError("This reset link is invalid or has expired. Request a new one below.")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusNotFound, "forgot.tmpl.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Whoever knew the previous password is logged out.
+	err = app.destroyUserSessions(r, userID, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	err := app.sessions.Delete(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) noteView(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.viewableNote(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+
+	if !app.isUnlocked(r, note) {
+		data.Form = noteUnlockForm{}
+		app.render(w, http.StatusOK, "unlock.tmpl.html", data)
+		return
+	}
+
+	// Reading a burn-after-reading note must be confirmed, so that it isn't destroyed by clients
+	// merely following its link, such as link previewers.
+	if note.Burn {
+		app.render(w, http.StatusOK, "burn.tmpl.html", data)
+		return
+	}
+
+	app.render(w, http.StatusOK, "view.tmpl.html", data)
+}
+
+type noteUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) noteUnlockPost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.viewableNote(w, r)
+	if !ok {
+		return
+	}
+
+	if !note.Protected {
+		http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+		return
+	}
+
+	var form noteUnlockForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+
+	// Attempts are limited per note, so that its password can't be guessed by brute force. They
+	// count as failures until one succeeds.
+	key := strconv.Itoa(note.ID)
+
+	if !app.unlockLimiter.Allow(key) {
+		form.AddNonFieldError("Too many incorrect passwords, please try again later")
+
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "unlock.tmpl.html", data)
+		return
+	}
+
+	err = app.notes.Unlock(note.ID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("Password is incorrect")
+
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl.html", data)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.unlockLimiter.Reset(key)
+	// Stores the moment until which the note can be read without its password.
+	app.sessionManager.Put(r.Context(), unlockKey(note.ID), time.Now().Add(unlockDuration).Unix())
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+}
+
+// Reveals a burn-after-reading note, deleting it in the process.
+func (app *application) noteBurnPost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.viewableNote(w, r)
+	if !ok {
+		return
+	}
+
+	if !note.Burn || !app.isUnlocked(r, note) {
+		http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+		return
+	}
+
+	// The note is only shown to whoever actually deleted it, not to concurrent readers.
+	err := app.notes.Burn(note.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+
+	app.render(w, http.StatusOK, "view.tmpl.html", data)
+}
+
+// Redirects links to notes using their sequential IDs, from before slugs were introduced. Only
+// public notes are redirected, since those are listed anyway, so the IDs can't be enumerated to
+// reach any other note.
+func (app *application) noteViewByID(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	note, err := app.notes.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if note.Visibility != models.VisibilityPublic {
+		app.notFound(w)
+		return
+	}
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusMovedPermanently)
+}
+
+type noteCreateForm struct {
+	Title      string `form:"title"`
+	Content    string `form:"content"`
+	Format     string `form:"format"`
+	Language   string `form:"language"`
+	Tags       string `form:"tags"`
+	Visibility string `form:"visibility"`
+	Burn       bool   `form:"burn"`
+	Password   string `form:"password"`
+	Expires    string `form:"expires"`
+	ExpiresAt  string `form:"expires_at"`
+	// Ignores field while encoding.
+	validator.Validator `form:"-"`
+}
+
+func (app *application) noteCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	// Sets any default or initial values for the form.
+	data.Form = noteCreateForm{
+		Format:     models.FormatPlain,
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
+	}
+
+	app.render(w, http.StatusOK, "create.tmpl.html", data)
+}
+
+// Returns the language the content of a note is highlighted in. Code pasted into plain notes is
+// highlighted even if its language wasn't specified.
+func noteLanguage(format, language, content string) string {
+	if format == models.FormatPlain && language == "" {
+		return markup.Detect(content)
+	}
+
+	return language
+}
+
+// Validates the form, returning the note it describes, which is only meaningful if the form is
+// valid.
+func checkNoteCreateForm(form *noteCreateForm) *models.Note {
+	form.CheckField(validator.NotBlank(form.Title), "title", "Field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "Field cannot exceed 100 characters")
+	form.CheckField(validator.NotBlank(form.Content), "content", "Field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Format, models.FormatPlain, models.FormatMarkdown), "format", "Field must be equal to plain or markdown")
+	form.CheckField(form.Language == "" || markup.IsLanguage(form.Language), "language", "Field must be a supported language")
+
+	tags := parseTags(form.Tags)
+
+	form.CheckField(len(tags) <= 10, "tags", "Field cannot have more than 10 tags")
+	form.CheckField(validator.AllMatch(tags, validator.TagRX), "tags", "Tags must have up to 30 lowercase letters, digits or hyphens")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "Field must be equal to public, unlisted or private")
+	form.CheckField(form.Password == "" || validator.MinChars(form.Password, 8), "password", "Field must be at least 8 characters long")
+	expires := checkExpiry(&form.Validator, form.Expires, form.ExpiresAt)
+
+	return &models.Note{
+		Title:      form.Title,
+		Content:    form.Content,
+		Format:     form.Format,
+		Language:   noteLanguage(form.Format, form.Language, form.Content),
+		Visibility: form.Visibility,
+		Burn:       form.Burn,
+		Tags:       tags,
+		Expires:    expires,
+	}
+}
+
+func (app *application) noteCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form noteCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	note := checkNoteCreateForm(&form)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		return
+	}
+
+	note.UserID = app.authenticatedUserID(r)
+
+	_, err = app.notes.Insert(note, form.Password)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if note.Burn {
+		app.sessionManager.Put(r.Context(), "flash", "Note successfully created! It will be deleted once read.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Note successfully created!")
+	}
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+}
+
+// Fields of an encrypted note, whose content is encrypted by the browser before being sent as
+// JSON. Tags, format and language are left out, as they would disclose what the note is about.
+type noteCreateEncryptedForm struct {
+	Title               string `json:"title"`
+	Content             string `json:"content"`
+	Visibility          string `json:"visibility"`
+	Burn                bool   `json:"burn"`
+	Expires             string `json:"expires"`
+	ExpiresAt           string `json:"expires_at"`
+	validator.Validator `json:"-"`
+}
+
+func (app *application) noteCreateEncrypted(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = noteCreateEncryptedForm{
+		Visibility: models.VisibilityUnlisted,
+		Expires:    "7d",
+	}
+
+	app.render(w, http.StatusOK, "encrypted.tmpl.html", data)
+}
+
+func (app *application) noteCreateEncryptedPost(w http.ResponseWriter, r *http.Request) {
+	var form noteCreateEncryptedForm
+
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "Field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "Field cannot exceed 100 characters")
+	form.CheckField(validator.NotBlank(form.Content), "content", "Field cannot be blank")
+	form.CheckField(validator.Matches(form.Content, validator.CiphertextRX), "content", "Field must be encrypted")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "Field must be equal to public, unlisted or private")
+	expires := checkExpiry(&form.Validator, form.Expires, form.ExpiresAt)
+
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	note := &models.Note{
+		UserID:     app.authenticatedUserID(r),
+		Title:      form.Title,
+		Content:    form.Content,
+		Format:     models.FormatPlain,
+		Visibility: form.Visibility,
+		Burn:       form.Burn,
+		Encrypted:  true,
+		Expires:    expires,
+	}
+
+	_, err = app.notes.Insert(note, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note successfully created! Its link includes the key needed to read it.")
+
+	// The browser appends the key to the URL itself, since it's never sent to the server.
+	app.writeJSON(w, http.StatusCreated, map[string]string{"slug": note.Slug, "url": "/n/" + note.Slug})
+}
+
+type noteEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Format              string `form:"format"`
+	Language            string `form:"language"`
+	Tags                string `form:"tags"`
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// Validates the form, applying its changes to the note, which is only meaningful if the form is
+// valid.
+func checkNoteEditForm(form *noteEditForm, note *models.Note) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "Field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "Field cannot exceed 100 characters")
+	form.CheckField(validator.NotBlank(form.Content), "content", "Field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Format, models.FormatPlain, models.FormatMarkdown), "format", "Field must be equal to plain or markdown")
+	form.CheckField(form.Language == "" || markup.IsLanguage(form.Language), "language", "Field must be a supported language")
+
+	tags := parseTags(form.Tags)
+
+	form.CheckField(len(tags) <= 10, "tags", "Field cannot have more than 10 tags")
+	form.CheckField(validator.AllMatch(tags, validator.TagRX), "tags", "Tags must have up to 30 lowercase letters, digits or hyphens")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "Field must be equal to public, unlisted or private")
+
+	note.Title = form.Title
+	note.Content = form.Content
+	note.Format = form.Format
+	note.Language = noteLanguage(form.Format, form.Language, form.Content)
+	note.Tags = tags
+	note.Visibility = form.Visibility
+}
+
+func (app *application) noteEdit(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+	// Encrypted notes can't be edited, since their key is never known by the server.
+	if note.Encrypted {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+	data.Form = noteEditForm{
+		Title:      note.Title,
+		Content:    note.Content,
+		Format:     note.Format,
+		Language:   note.Language,
+		Tags:       strings.Join(note.Tags, ", "),
+		Visibility: note.Visibility,
+	}
+
+	app.render(w, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) noteEditPost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+	// Encrypted notes can't be edited, since their key is never known by the server.
+	if note.Encrypted {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form noteEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkNoteEditForm(&form, note)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Note = note
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.notes.Update(note)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note successfully updated!")
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+}
+
+func (app *application) noteDeletePost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.notes.Delete(note.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note moved to the trash!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) trash(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	notes, err := app.notes.Trash(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Notes = notes
+	data.TrashRetentionDays = int(app.trashRetention.Hours() / 24)
+
+	app.render(w, http.StatusOK, "trash.tmpl.html", data)
+}
+
+func (app *application) trashRestorePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Notes in the trash of other users are reported as missing.
+	err = app.notes.Restore(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note successfully restored!")
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+func (app *application) trashPurgePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.notes.Purge(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note permanently deleted!")
+
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+type noteExtendForm struct {
+	Expires             string `form:"expires"`
+	ExpiresAt           string `form:"expires_at"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) noteExtend(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+	data.Form = noteExtendForm{
+		Expires: "30d",
+	}
+
+	app.render(w, http.StatusOK, "extend.tmpl.html", data)
+}
+
+func (app *application) noteExtendPost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+
+	var form noteExtendForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	expires := checkExpiry(&form.Validator, form.Expires, form.ExpiresAt)
+
+	// Notes can only be kept for longer, never less than they already are.
+	if form.Valid() {
+		form.CheckField(expires.IsZero() || (!note.Expires.IsZero() && expires.After(note.Expires)), "expires", "Field must be later than the current expiry")
+	}
+
 	if !form.Valid() {
 		data := app.newTemplateData(r)
+		data.Note = note
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "extend.tmpl.html", data)
+		return
+	}
+
+	err = app.notes.Extend(note.ID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Note expiry successfully extended!")
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+}
+
+type noteHistoryForm struct {
+	From int `form:"from"`
+	To   int `form:"to"`
+}
+
+func (app *application) noteHistory(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.viewableNote(w, r)
+	if !ok {
+		return
+	}
+	// The revisions of a burn-after-reading note would disclose its content without burning it,
+	// while the ones of an encrypted note are ciphertexts which can't be compared.
+	if note.Burn || note.Encrypted {
+		app.notFound(w)
+		return
+	}
+
+	if !app.isUnlocked(r, note) {
+		http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.notes.Revisions(note.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// By default, the most recent revision is compared against the one preceding it.
+	form := noteHistoryForm{
+		From: max(len(revisions)-1, 1),
+		To:   len(revisions),
+	}
+
+	err = app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Note = note
+	data.Revisions = revisions
+	data.Form = form
+
+	if len(revisions) > 0 {
+		if form.From < 1 || form.From > len(revisions) || form.To < 1 || form.To > len(revisions) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		data.Diff = diff.Lines(revisions[form.From-1].Content, revisions[form.To-1].Content)
+	}
+
+	app.render(w, http.StatusOK, "history.tmpl.html", data)
+}
+
+type noteRestoreForm struct {
+	Revision int `form:"revision"`
+}
+
+func (app *application) noteRestorePost(w http.ResponseWriter, r *http.Request) {
+	note, ok := app.ownedNote(w, r)
+	if !ok {
+		return
+	}
+	// Encrypted notes have no history to restore from, see noteHistory.
+	if note.Encrypted {
+		app.notFound(w)
+		return
+	}
+
+	var form noteRestoreForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := app.notes.Revisions(note.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if form.Revision < 1 || form.Revision > len(revisions) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revision := revisions[form.Revision-1]
+
+	// Restoring is recorded as a new revision, so the history is never rewritten.
+	note.Title = revision.Title
+	note.Content = revision.Content
+	note.Format = revision.Format
+	note.Language = revision.Language
+
+	err = app.notes.Update(note)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Note restored to revision %d!", revision.Number))
+
+	http.Redirect(w, r, "/n/"+note.Slug, http.StatusSeeOther)
+}
+
+type noteListForm struct {
+	Before              int `form:"before"`
+	After               int `form:"after"`
+	Limit               int `form:"limit"`
+	validator.Validator `form:"-"`
+}
+
+func checkNoteListForm(form *noteListForm) {
+	form.CheckField(form.Before >= 0, "before", "Field must be a valid note ID")
+	form.CheckField(form.After >= 0, "after", "Field must be a valid note ID")
+	form.CheckField(form.Before == 0 || form.After == 0, "after", "Field cannot be used along with before")
+	form.CheckField(validator.InRange(form.Limit, 1, 100), "limit", "Field must be between 1 and 100")
+}
+
+// Retrieves the page of notes requested through the valid form, along with the queries of the
+// pages preceding and following it, which are blank when there are none.
+func (app *application) listNotes(form noteListForm) ([]*models.Note, string, string, error) {
+	// An extra note is requested to find out whether there is another page past this one.
+	notes, err := app.notes.List(form.Before, form.After, form.Limit+1)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	more := len(notes) > form.Limit
+	if more {
+		if form.After > 0 {
+			notes = notes[1:]
+		} else {
+			notes = notes[:form.Limit]
+		}
+	}
+
+	pageQuery := func(cursor string, id int) string {
+		v := url.Values{}
+		v.Set(cursor, strconv.Itoa(id))
+		v.Set("limit", strconv.Itoa(form.Limit))
+		return v.Encode()
+	}
+
+	var prev, next string
+
+	if len(notes) > 0 {
+		// Going backwards, the next page is the one the user came from, and vice versa.
+		if form.Before > 0 || (form.After > 0 && more) {
+			prev = pageQuery("after", notes[0].ID)
+		}
+		if form.After > 0 || more {
+			next = pageQuery("before", notes[len(notes)-1].ID)
+		}
+	}
+
+	return notes, prev, next, nil
+}
+
+func (app *application) noteList(w http.ResponseWriter, r *http.Request) {
+	form := noteListForm{
+		Limit: 20,
+	}
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkNoteListForm(&form)
+
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	notes, prev, next, err := app.listNotes(form)
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Note successfully created!")
+	data := app.newTemplateData(r)
+	data.Notes = notes
+
+	if prev != "" {
+		data.PrevURL = "/notes?" + prev
+	}
+	if next != "" {
+		data.NextURL = "/notes?" + next
+	}
+
+	app.render(w, http.StatusOK, "notes.tmpl.html", data)
+}
+
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tag := params.ByName("name")
+	if !validator.Matches(tag, validator.TagRX) {
+		app.notFound(w)
+		return
+	}
 
-	id, err := app.notes.Insert(form.Title, form.Content, form.Expires)
+	notes, err := app.notes.ByTag(tag)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/note/view/%d", id), http.StatusSeeOther)
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Notes = notes
+
+	app.render(w, http.StatusOK, "tag.tmpl.html", data)
+}
+
+// Last page of search results that can be requested, which keeps the offset of the page from
+// overflowing.
+const maxSearchPage = 1000
+
+type searchForm struct {
+	Query string `form:"q"`
+	Page  int    `form:"page"`
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	form := searchForm{
+		Page: 1,
+	}
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil || !validator.InRange(form.Page, 1, maxSearchPage) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if validator.NotBlank(form.Query) {
+		notes, err := app.notes.Search(form.Query, form.Page)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Notes = notes
+
+		pageURL := func(page int) string {
+			v := url.Values{}
+			v.Set("q", form.Query)
+			v.Set("page", strconv.Itoa(page))
+			return "/search?" + v.Encode()
+		}
+
+		if form.Page > 1 {
+			data.PrevURL = pageURL(form.Page - 1)
+		}
+		// A full page indicates there may be more results to display.
+		if len(notes) == models.SearchPageSize {
+			data.NextURL = pageURL(form.Page + 1)
+		}
+	}
+
+	app.render(w, http.StatusOK, "search.tmpl.html", data)
 }
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
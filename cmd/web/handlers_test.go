@@ -1,11 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gustavodiasag/notebox/internal/assert"
+	"github.com/gustavodiasag/notebox/internal/mailer"
+	"github.com/gustavodiasag/notebox/internal/models"
+	"github.com/gustavodiasag/notebox/internal/models/mocks"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -33,11 +40,232 @@ func TestNoteView(t *testing.T) {
 		wantBody string
 	}{
 		{
-			name:     "Valid ID",
-			urlPath:  "/note/view/1",
+			name:     "Valid slug",
+			urlPath:  "/n/note000001",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond",
 		},
+		{
+			name:     "Slug badge",
+			urlPath:  "/n/note000001",
+			wantCode: http.StatusOK,
+			wantBody: "<span>#note000001</span>",
+		},
+		{
+			name:     "Markdown note",
+			urlPath:  "/n/note000004",
+			wantCode: http.StatusOK,
+			wantBody: "<h1>Haiku</h1>",
+		},
+		{
+			name:     "Code note",
+			urlPath:  "/n/note000005",
+			wantCode: http.StatusOK,
+			wantBody: `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Private note",
+			urlPath:  "/n/note000006",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Burn-after-reading note",
+			urlPath:  "/n/note000007",
+			wantCode: http.StatusOK,
+			wantBody: "This note will self-destruct",
+		},
+		{
+			name:     "Protected note",
+			urlPath:  "/n/note000008",
+			wantCode: http.StatusOK,
+			wantBody: "This note is protected",
+		},
+		{
+			name:     "Encrypted note",
+			urlPath:  "/n/note000009",
+			wantCode: http.StatusOK,
+			wantBody: "data-ciphertext='AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGw=='",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/n/note000002",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Short slug",
+			urlPath:  "/n/note",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-alphanumeric slug",
+			urlPath:  "/n/note-00001",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNoteBurn(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/n/note000007")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	// The interstitial page must not disclose the content of the note.
+	assert.Equal(t, strings.Contains(body, "1234-5678"), false)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+		wantRedir string
+	}{
+		{
+			name:      "Burn-after-reading note",
+			urlPath:   "/n/note000007",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusOK,
+			wantBody:  "1234-5678",
+		},
+		{
+			name:      "Regular note",
+			urlPath:   "/n/note000001",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/n/note000001",
+		},
+		{
+			name:      "Private note",
+			urlPath:   "/n/note000006",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusNotFound,
+		},
+		{
+			name:      "Invalid CSRF token",
+			urlPath:   "/n/note000007",
+			csrfToken: "invalidToken",
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.wantRedir != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantRedir)
+			}
+		})
+	}
+}
+
+func TestNoteUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/n/note000008")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.Equal(t, strings.Contains(body, "correct horse battery staple"), false)
+
+	unlock := func(password string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", validCSRFToken)
+
+		return ts.postForm(t, "/n/note000008/unlock", form)
+	}
+
+	t.Run("Incorrect password", func(t *testing.T) {
+		code, _, body := unlock("wrong")
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Password is incorrect")
+	})
+
+	t.Run("Correct password", func(t *testing.T) {
+		code, headers, _ := unlock("pass")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/n/note000008")
+
+		code, _, body := ts.get(t, "/n/note000008")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "correct horse battery staple")
+	})
+
+	t.Run("Too many attempts", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			unlock("wrong")
+		}
+
+		code, _, body := unlock("pass")
+
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.StringContains(t, body, "Too many incorrect passwords")
+	})
+
+	t.Run("Unprotected note", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "pass")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, headers, _ := ts.postForm(t, "/n/note000001/unlock", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/n/note000001")
+	})
+}
+
+func TestNoteViewByID(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantRedir string
+	}{
+		{
+			name:      "Public note",
+			urlPath:   "/note/view/1",
+			wantCode:  http.StatusMovedPermanently,
+			wantRedir: "/n/note000001",
+		},
+		{
+			name:     "Private note",
+			urlPath:  "/note/view/6",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/note/view/2",
@@ -58,21 +286,16 @@ func TestNoteView(t *testing.T) {
 			urlPath:  "/note/view/foo",
 			wantCode: http.StatusNotFound,
 		},
-		{
-			name:     "Empty ID",
-			urlPath:  "/note/view/",
-			wantCode: http.StatusNotFound,
-		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			code, _, body := ts.get(t, tt.urlPath)
+			code, headers, _ := ts.get(t, tt.urlPath)
 
 			assert.Equal(t, code, tt.wantCode)
 
-			if tt.wantBody != "" {
-				assert.StringContains(t, body, tt.wantBody)
+			if tt.wantRedir != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantRedir)
 			}
 		})
 	}
@@ -193,32 +416,1693 @@ func TestUserSignup(t *testing.T) {
 	}
 }
 
-func TestNoteCreate(t *testing.T) {
+func TestUserSignupEmail(t *testing.T) {
 	app := newTestApplication(t)
+
+	var mailbox bytes.Buffer
+	app.mailer = mailer.NewLog(&mailbox, "no-reply@example.com")
+
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	t.Run("Unauthenticated", func(t *testing.T) {
-		code, headers, _ := ts.get(t, "/note/create")
+	_, _, body := ts.get(t, "/user/signup")
 
-		assert.Equal(t, code, http.StatusSeeOther)
-		assert.Equal(t, headers.Get("Location"), "/user/login")
-	})
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validpass")
+	form.Add("csrf_token", extractCSRFToken(t, body))
 
-	t.Run("Authenticated", func(t *testing.T) {
-		_, _, body := ts.get(t, "/user/login")
-		csrfToken := extractCSRFToken(t, body)
+	code, headers, _ := ts.postForm(t, "/user/signup", form)
 
-		form := url.Values{}
-		form.Add("email", "alice@example.com")
-		form.Add("password", "pass")
-		form.Add("csrf_token", csrfToken)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
 
-		ts.postForm(t, "/user/login", form)
+	// The email is sent in the background.
+	app.wg.Wait()
 
-		code, _, body := ts.get(t, "/note/create")
+	assert.StringContains(t, mailbox.String(), "To: bob@example.com")
+	assert.StringContains(t, mailbox.String(), "https://localhost:4000/user/verify/verificationtoken")
 
-		assert.Equal(t, code, http.StatusOK)
-		assert.StringContains(t, body, "<form action='/note/create' method='POST'>")
+	_, _, body = ts.get(t, "/user/login")
+	assert.StringContains(t, body, "Check your email to verify your address")
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid token",
+			urlPath:      "/user/verify/validtoken",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid token",
+			urlPath:  "/user/verify/invalidtoken",
+			wantCode: http.StatusNotFound,
+			wantBody: "This verification link is invalid or has expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserVerifyPost(t *testing.T) {
+	app := newTestApplication(t)
+
+	var mailbox bytes.Buffer
+	app.mailer = mailer.NewLog(&mailbox, "no-reply@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/verify")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantMail string
+	}{
+		{
+			name:     "Unverified account",
+			email:    "carol@example.com",
+			wantCode: http.StatusSeeOther,
+			wantMail: "/user/verify/newtoken",
+		},
+		{
+			name:     "Verified account",
+			email:    "alice@example.com",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Unknown address",
+			email:    "dave@example.com",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Invalid email",
+			email:    "mail",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailbox.Reset()
+
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, "/user/verify", form)
+			app.wg.Wait()
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantMail != "" {
+				assert.StringContains(t, mailbox.String(), tt.wantMail)
+			} else {
+				assert.Equal(t, mailbox.Len(), 0)
+			}
+		})
+	}
+}
+
+func TestPasswordForgot(t *testing.T) {
+	app := newTestApplication(t)
+
+	var mailbox bytes.Buffer
+	app.mailer = mailer.NewLog(&mailbox, "no-reply@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantMail string
+	}{
+		{
+			name:     "Known address",
+			email:    "alice@example.com",
+			wantCode: http.StatusSeeOther,
+			wantMail: "https://localhost:4000/user/password/reset/resettoken",
+		},
+		{
+			name:     "Unknown address",
+			email:    "dave@example.com",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Invalid email",
+			email:    "mail",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailbox.Reset()
+
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, "/user/password/forgot", form)
+			app.wg.Wait()
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantMail != "" {
+				assert.StringContains(t, mailbox.String(), tt.wantMail)
+			} else {
+				assert.Equal(t, mailbox.Len(), 0)
+			}
+		})
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Another device in which the user is logged in.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t)
+
+	code, _, _ := other.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	_, _, body := ts.get(t, "/user/password/reset/resettoken")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.StringContains(t, body, "<form action='/user/password/reset/resettoken' method='POST' novalidate>")
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		confirm  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Short password",
+			token:    "resettoken",
+			password: "pass",
+			confirm:  "pass",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Field must be at least 8 characters long",
+		},
+		{
+			name:     "Passwords do not match",
+			token:    "resettoken",
+			password: "newpassword",
+			confirm:  "otherpassword",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Passwords do not match",
+		},
+		{
+			name:     "Invalid token",
+			token:    "invalidtoken",
+			password: "newpassword",
+			confirm:  "newpassword",
+			wantCode: http.StatusNotFound,
+			wantBody: "This reset link is invalid or has expired",
+		},
+		{
+			name:     "Valid submission",
+			token:    "resettoken",
+			password: "newpassword",
+			confirm:  "newpassword",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("new", tt.password)
+			form.Add("confirm", tt.confirm)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/user/password/reset/"+tt.token, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Sessions invalidated", func(t *testing.T) {
+		code, headers, _ := other.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+}
+
+func TestTOTPSetup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/totp/setup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "src='/account/totp/qrcode.png'")
+	assert.StringContains(t, body, "JBSWY3DPEHPK3PXP")
+
+	t.Run("QR code", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/account/totp/qrcode.png")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "image/png")
+		assert.Equal(t, strings.HasPrefix(body, "\x89PNG"), true)
+	})
+
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Malformed code",
+			code:     "abc",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Field must be the 6-digit code shown by the app",
+		},
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Invalid code",
+		},
+		{
+			name:     "Valid code",
+			code:     "123456",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("code", tt.code)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/account/totp/setup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				// The same secret is shown again.
+				assert.StringContains(t, body, "JBSWY3DPEHPK3PXP")
+			}
+		})
+	}
+
+	t.Run("Recovery codes shown once", func(t *testing.T) {
+		_, _, body := ts.get(t, "/account/view")
+		assert.StringContains(t, body, "aaaaa-bbbbb")
+		assert.StringContains(t, body, "download='notebox-recovery-codes.txt'")
+
+		_, _, body = ts.get(t, "/account/view")
+		assert.Equal(t, strings.Contains(body, "aaaaa-bbbbb"), false)
+	})
+}
+
+func TestUserLoginTOTP(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Logs in as Dave, who enabled two-factor authentication, returning the CSRF token to submit
+	// the code with.
+	loginWithPassword := func(t *testing.T, ts *testServer) string {
+		_, _, body := ts.get(t, "/user/login")
+
+		form := url.Values{}
+		form.Add("email", "dave@example.com")
+		form.Add("password", "pass")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, "/user/login", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login/totp")
+
+		_, _, body = ts.get(t, "/user/login/totp")
+
+		return extractCSRFToken(t, body)
+	}
+
+	submitCode := func(t *testing.T, ts *testServer, csrfToken, code string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("code", code)
+		form.Add("csrf_token", csrfToken)
+
+		return ts.postForm(t, "/user/login/totp", form)
+	}
+
+	t.Run("Without password", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, headers, _ := ts.get(t, "/user/login/totp")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Valid code", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		csrfToken := loginWithPassword(t, ts)
+
+		// The password alone doesn't log the user in.
+		code, headers, _ := ts.get(t, "/note/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		code, _, body := submitCode(t, ts, csrfToken, "000000")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Invalid authentication code")
+
+		code, headers, _ = submitCode(t, ts, csrfToken, "123456")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/note/create")
+
+		code, _, _ = ts.get(t, "/note/create")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		csrfToken := loginWithPassword(t, ts)
+
+		code, _, _ := submitCode(t, ts, csrfToken, "AAAAA-BBBBB")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		code, _, _ = submitCode(t, ts, csrfToken, "aaaaa-bbbbb")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/note/create")
+		assert.StringContains(t, body, "You logged in with a recovery code")
+	})
+
+	t.Run("Too many attempts", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		csrfToken := loginWithPassword(t, ts)
+
+		for i := 1; i < totpLoginAttempts; i++ {
+			code, _, _ := submitCode(t, ts, csrfToken, "000000")
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+		}
+
+		code, headers, _ := submitCode(t, ts, csrfToken, "000000")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		// The right code is no longer enough, the password has to be entered again.
+		code, headers, _ = submitCode(t, ts, csrfToken, "123456")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+}
+
+func TestUserLoginThrottling(t *testing.T) {
+	login := func(t *testing.T, ts *testServer, email, password string) (int, string) {
+		_, _, body := ts.get(t, "/user/login")
+
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", password)
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, body := ts.postForm(t, "/user/login", form)
+
+		return code, body
+	}
+
+	t.Run("Per account", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		for i := 0; i < 6; i++ {
+			code, _ := login(t, ts, "alice@example.com", "wrongpass")
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+		}
+
+		// Even the right password is refused while the account is locked out.
+		code, body := login(t, ts, "ALICE@example.com", "pass")
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.StringContains(t, body, "Too many failed login attempts, please try again in 30 seconds")
+
+		// Other accounts can still log in from the same address.
+		code, _ = login(t, ts, "dave@example.com", "pass")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		app.wg.Wait()
+
+		lockouts := app.lockouts.(*mocks.LockoutModel).Recorded()
+		assert.Equal(t, len(lockouts), 1)
+		assert.Equal(t, lockouts[0].Kind, models.LockoutAccount)
+		assert.Equal(t, lockouts[0].Subject, "alice@example.com")
+		assert.Equal(t, lockouts[0].IP, "127.0.0.1")
+	})
+
+	t.Run("Per IP address", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.ipBackoff = newBackoff(2, 30*time.Second, 15*time.Minute, time.Hour)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		for _, email := range []string{"bob@example.com", "carol@example.com", "dave@example.com"} {
+			code, _ := login(t, ts, email, "wrongpass")
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+		}
+
+		code, body := login(t, ts, "alice@example.com", "pass")
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.StringContains(t, body, "Too many failed login attempts")
+
+		app.wg.Wait()
+
+		lockouts := app.lockouts.(*mocks.LockoutModel).Recorded()
+		assert.Equal(t, len(lockouts), 1)
+		assert.Equal(t, lockouts[0].Kind, models.LockoutIP)
+		assert.Equal(t, lockouts[0].Subject, "127.0.0.1")
+	})
+}
+
+func TestFmtWait(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		want string
+	}{
+		{
+			name: "Under a second",
+			d:    300 * time.Millisecond,
+			want: "1 second",
+		},
+		{
+			name: "Seconds",
+			d:    29*time.Second + 100*time.Millisecond,
+			want: "30 seconds",
+		},
+		{
+			name: "Minute",
+			d:    time.Minute,
+			want: "60 seconds",
+		},
+		{
+			name: "Minutes",
+			d:    4*time.Minute + time.Second,
+			want: "5 minutes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmtWait(tt.d), tt.want)
+		})
+	}
+}
+
+func TestUserLoginUnverified(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", "carol@example.com")
+	form.Add("password", "pass")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/user/login", form)
+
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Your email address isn&#39;t verified yet")
+	assert.StringContains(t, body, "<a href='/user/verify'>")
+}
+
+func TestNoteCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/note/create")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/login")
+		csrfToken := extractCSRFToken(t, body)
+
+		form := url.Values{}
+		form.Add("email", "alice@example.com")
+		form.Add("password", "pass")
+		form.Add("csrf_token", csrfToken)
+
+		ts.postForm(t, "/user/login", form)
+
+		code, _, body := ts.get(t, "/note/create")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/note/create' method='POST'>")
+	})
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>Alice</td>")
+	assert.StringContains(t, body, "<td>#note000001</td>")
+}
+
+func TestNoteEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/note/edit/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/edit/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		title     string
+		content   string
+		wantCode  int
+		wantBody  string
+		wantRedir string
+	}{
+		{
+			name:      "Valid submission",
+			urlPath:   "/note/edit/1",
+			title:     "A frog jumps",
+			content:   "The sound of water",
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/n/note000001",
+		},
+		{
+			name:     "Encrypted note",
+			urlPath:  "/note/edit/9",
+			title:    "A frog jumps",
+			content:  "The sound of water",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Empty title",
+			urlPath:  "/note/edit/1",
+			title:    "",
+			content:  "The sound of water",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "<form action='/note/edit/1' method='POST'>",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/note/edit/2",
+			title:    "A frog jumps",
+			content:  "The sound of water",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Not the author",
+			urlPath:  "/note/edit/3",
+			title:    "A frog jumps",
+			content:  "The sound of water",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("format", "plain")
+			form.Add("visibility", "public")
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantRedir != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantRedir)
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCheckNoteEditForm(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		language string
+		content  string
+		want     string
+	}{
+		{
+			name:    "Detected language",
+			format:  models.FormatPlain,
+			content: "#!/bin/bash\necho foo",
+			want:    "bash",
+		},
+		{
+			name:     "Given language",
+			format:   models.FormatPlain,
+			language: "go",
+			content:  "#!/bin/bash\necho foo",
+			want:     "go",
+		},
+		{
+			name:    "Markdown",
+			format:  models.FormatMarkdown,
+			content: "#!/bin/bash\necho foo",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := noteEditForm{
+				Title:      "Script",
+				Content:    tt.content,
+				Format:     tt.format,
+				Language:   tt.language,
+				Visibility: models.VisibilityPublic,
+			}
+			note := &models.Note{}
+
+			checkNoteEditForm(&form, note)
+
+			assert.Equal(t, form.Valid(), true)
+			assert.Equal(t, note.Language, tt.want)
+		})
+	}
+}
+
+func TestNoteDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/n/note000001")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Author",
+			urlPath:  "/note/delete/1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Not the author",
+			urlPath:  "/note/delete/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/note/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestNoteHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Default comparison",
+			urlPath:  "/n/note000001/history",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='diff-delete'>An old pond</span>",
+		},
+		{
+			name:     "Explicit comparison",
+			urlPath:  "/n/note000001/history?from=2&to=1",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='diff-insert'>An old pond</span>",
+		},
+		{
+			name:     "Out of range revision",
+			urlPath:  "/n/note000001/history?from=1&to=3",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid revision",
+			urlPath:  "/n/note000001/history?from=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Private note",
+			urlPath:  "/n/note000006/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Burn-after-reading note",
+			urlPath:  "/n/note000007/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Encrypted note",
+			urlPath:  "/n/note000009/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/n/note000002/history",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNoteRestore(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/n/note000001/history")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		revision string
+		wantCode int
+	}{
+		{
+			name:     "Valid revision",
+			urlPath:  "/note/restore/1",
+			revision: "1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/note/restore/1",
+			revision: "3",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Not the author",
+			urlPath:  "/note/restore/3",
+			revision: "1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Encrypted note",
+			urlPath:  "/note/restore/9",
+			revision: "1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("revision", tt.revision)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantBody   string
+		unwantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/search' method='GET'>",
+		},
+		{
+			name:     "Matching query",
+			urlPath:  "/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: "<mark>pond</mark>",
+		},
+		{
+			name:     "No results",
+			urlPath:  "/search?q=firefly",
+			wantCode: http.StatusOK,
+			wantBody: "No notes found",
+		},
+		{
+			name:     "Second page",
+			urlPath:  "/search?q=pond&page=2",
+			wantCode: http.StatusOK,
+			wantBody: "/search?page=1&amp;q=pond",
+		},
+		{
+			name:       "Protected note",
+			urlPath:    "/search?q=battery",
+			wantCode:   http.StatusOK,
+			wantBody:   "No notes found",
+			unwantBody: "correct horse",
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/search?q=pond&page=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page too large",
+			urlPath:  "/search?q=pond&page=9223372036854775807",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.unwantBody != "" && strings.Contains(body, tt.unwantBody) {
+				t.Errorf("got: %q; expected not to contain: %q", body, tt.unwantBody)
+			}
+		})
+	}
+}
+
+func TestNoteList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantCode   int
+		wantBody   string
+		unwantBody string
+	}{
+		{
+			name:       "First page",
+			urlPath:    "/notes",
+			wantCode:   http.StatusOK,
+			wantBody:   "An old silent pond",
+			unwantBody: "class='pagination'",
+		},
+		{
+			name:     "Before cursor",
+			urlPath:  "/notes?before=2&limit=10",
+			wantCode: http.StatusOK,
+			wantBody: "/notes?after=1&amp;limit=10",
+		},
+		{
+			name:     "Ahead of the newest note",
+			urlPath:  "/notes?after=1",
+			wantCode: http.StatusOK,
+			wantBody: "Empty",
+		},
+		{
+			name:     "Past the last note",
+			urlPath:  "/notes?before=1",
+			wantCode: http.StatusOK,
+			wantBody: "Empty",
+		},
+		{
+			name:     "Both cursors",
+			urlPath:  "/notes?before=5&after=1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid limit",
+			urlPath:  "/notes?limit=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/notes?before=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.unwantBody != "" && strings.Contains(body, tt.unwantBody) {
+				t.Errorf("got: %q; expected not to contain: %q", body, tt.unwantBody)
+			}
+		})
+	}
+}
+
+func TestNoteCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		format     string
+		language   string
+		tags       string
+		visibility string
+		burn       string
+		password   string
+		expires    string
+		expiresAt  string
+		wantCode   int
+	}{
+		{
+			name:       "Plain text",
+			format:     "plain",
+			visibility: "public",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Code",
+			format:     "plain",
+			visibility: "public",
+			language:   "go",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Markdown",
+			format:     "markdown",
+			visibility: "public",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Tags",
+			format:     "plain",
+			visibility: "public",
+			tags:       "Go, networking, go, ",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Invalid tags",
+			format:     "plain",
+			visibility: "public",
+			tags:       "go, not a tag",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Burn after reading",
+			format:     "plain",
+			visibility: "unlisted",
+			burn:       "true",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Password",
+			format:     "plain",
+			visibility: "unlisted",
+			password:   "pa$$word",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Short password",
+			format:     "plain",
+			visibility: "unlisted",
+			password:   "pass",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Never expires",
+			format:     "plain",
+			visibility: "public",
+			expires:    "never",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Specific expiry",
+			format:     "plain",
+			visibility: "public",
+			expires:    "at",
+			expiresAt:  time.Now().UTC().AddDate(0, 0, 3).Format("2006-01-02T15:04"),
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Past expiry",
+			format:     "plain",
+			visibility: "public",
+			expires:    "at",
+			expiresAt:  "2000-01-01T00:00",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid expiry",
+			format:     "plain",
+			visibility: "public",
+			expires:    "2d",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Unlisted",
+			format:     "plain",
+			visibility: "unlisted",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Invalid visibility",
+			format:     "plain",
+			visibility: "secret",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid format",
+			format:     "html",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid language",
+			format:     "plain",
+			visibility: "public",
+			language:   "klingon",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "An old silent pond")
+			form.Add("content", "A frog jumps into the pond")
+			form.Add("format", tt.format)
+			form.Add("language", tt.language)
+			form.Add("tags", tt.tags)
+			form.Add("visibility", tt.visibility)
+			if tt.burn != "" {
+				form.Add("burn", tt.burn)
+			}
+			form.Add("password", tt.password)
+			if tt.expires == "" {
+				tt.expires = "7d"
+			}
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, "/note/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Existing tag",
+			urlPath:  "/tag/haiku",
+			wantCode: http.StatusOK,
+			wantBody: "<a class='tag' href='/tag/nature'>nature</a>",
+		},
+		{
+			name:     "Unused tag",
+			urlPath:  "/tag/prose",
+			wantCode: http.StatusOK,
+			wantBody: "Empty",
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/tag/Haiku",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNoteExtend(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/extend/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		expires   string
+		wantCode  int
+		wantBody  string
+		wantRedir string
+	}{
+		{
+			name:      "Valid submission",
+			urlPath:   "/note/extend/1",
+			expires:   "30d",
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/n/note000001",
+		},
+		{
+			name:      "Never expires",
+			urlPath:   "/note/extend/1",
+			expires:   "never",
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/n/note000001",
+		},
+		{
+			name:     "Shorter expiry",
+			urlPath:  "/note/extend/9",
+			expires:  "30d",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Field must be later than the current expiry",
+		},
+		{
+			name:     "Invalid expiry",
+			urlPath:  "/note/extend/1",
+			expires:  "2d",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Field must be a permitted expiry",
+		},
+		{
+			name:     "Foreign note",
+			urlPath:  "/note/extend/3",
+			expires:  "30d",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/note/extend/2",
+			expires:  "30d",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.wantRedir != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantRedir)
+			}
+		})
+	}
+}
+
+func TestNoteCreateEncryptedPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/note/create/encrypted")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	// The plaintext can't be submitted without being encrypted by the script first.
+	if strings.Contains(body, "name='content'") {
+		t.Errorf("got: %q; expected not to contain: %q", body, "name='content'")
+	}
+
+	const ciphertext = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGw=="
+
+	tests := []struct {
+		name      string
+		body      string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Valid submission",
+			body:      `{"title": "Secret haiku", "content": "` + ciphertext + `", "visibility": "unlisted", "expires": "7d"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusCreated,
+			wantBody:  `"url":"/n/`,
+		},
+		{
+			name:      "Plaintext content",
+			body:      `{"title": "Secret haiku", "content": "An old silent pond", "visibility": "unlisted", "expires": "7d"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  `"content":"Field must be encrypted"`,
+		},
+		{
+			name:      "Empty title",
+			body:      `{"title": "", "content": "` + ciphertext + `", "visibility": "unlisted", "expires": "7d"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  `"title":"Field cannot be blank"`,
+		},
+		{
+			name:      "Unknown field",
+			body:      `{"title": "Secret haiku", "content": "` + ciphertext + `", "format": "markdown"}`,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "Malformed JSON",
+			body:      `{"title": `,
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "Invalid CSRF token",
+			body:      `{"title": "Secret haiku", "content": "` + ciphertext + `", "visibility": "unlisted", "expires": "7d"}`,
+			csrfToken: "invalidToken",
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/note/create/encrypted", tt.body, tt.csrfToken)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/trash")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/trash")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "A giant firefly")
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantRedir string
+	}{
+		{
+			name:      "Restore",
+			urlPath:   "/trash/restore/10",
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/trash",
+		},
+		{
+			name:      "Purge",
+			urlPath:   "/trash/purge/10",
+			wantCode:  http.StatusSeeOther,
+			wantRedir: "/trash",
+		},
+		{
+			name:     "Restore note outside the trash",
+			urlPath:  "/trash/restore/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Purge note outside the trash",
+			urlPath:  "/trash/purge/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/trash/purge/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantRedir != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantRedir)
+			}
+		})
+	}
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/view")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>CI</td>")
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			tokenName string
+			scope     string
+			wantCode  int
+			wantBody  string
+		}{
+			{
+				name:      "Empty name",
+				tokenName: "",
+				scope:     "read",
+				wantCode:  http.StatusUnprocessableEntity,
+				wantBody:  "Field cannot be blank",
+			},
+			{
+				name:      "Invalid scope",
+				tokenName: "Deploy",
+				scope:     "admin",
+				wantCode:  http.StatusUnprocessableEntity,
+				wantBody:  "Field must be equal to read or write",
+			},
+			{
+				name:      "Valid submission",
+				tokenName: "Deploy",
+				scope:     "write",
+				wantCode:  http.StatusSeeOther,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				form := url.Values{}
+				form.Add("name", tt.tokenName)
+				form.Add("scope", tt.scope)
+				form.Add("csrf_token", validCSRFToken)
+
+				code, _, body := ts.postForm(t, "/account/tokens/create", form)
+
+				assert.Equal(t, code, tt.wantCode)
+
+				if tt.wantBody != "" {
+					assert.StringContains(t, body, tt.wantBody)
+				}
+			})
+		}
+	})
+
+	t.Run("Shown once", func(t *testing.T) {
+		_, _, body := ts.get(t, "/account/view")
+		assert.StringContains(t, body, "nb_newtoken")
+
+		_, _, body = ts.get(t, "/account/view")
+		assert.Equal(t, strings.Contains(body, "nb_newtoken"), false)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			urlPath  string
+			wantCode int
+		}{
+			{
+				name:     "Own token",
+				urlPath:  "/account/tokens/revoke/1",
+				wantCode: http.StatusSeeOther,
+			},
+			{
+				name:     "Non-existent ID",
+				urlPath:  "/account/tokens/revoke/2",
+				wantCode: http.StatusNotFound,
+			},
+			{
+				name:     "String ID",
+				urlPath:  "/account/tokens/revoke/foo",
+				wantCode: http.StatusNotFound,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				form := url.Values{}
+				form.Add("csrf_token", validCSRFToken)
+
+				code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+				assert.Equal(t, code, tt.wantCode)
+			})
+		}
+	})
+}
+
+var sessionRevokeRX = regexp.MustCompile(`/account/sessions/revoke/([0-9a-f]+)`)
+
+func TestAccountSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Another device in which the user is logged in.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	ts.login(t)
+	other.login(t)
+
+	code, _, body := ts.get(t, "/account/view")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This session")
+	assert.StringContains(t, body, "Go-http-client")
+	assert.StringContains(t, body, "127.0.0.1")
+
+	// Only the other session can be revoked from the list.
+	matches := sessionRevokeRX.FindAllStringSubmatch(body, -1)
+	assert.Equal(t, len(matches), 1)
+
+	loggedIn := func(t *testing.T, ts *testServer) bool {
+		code, _, _ := ts.get(t, "/account/view")
+		return code == http.StatusOK
+	}
+
+	t.Run("Revoke", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, _ := ts.postForm(t, "/account/sessions/revoke/0123456789abcdef0123456789abcdef", form)
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, loggedIn(t, other), true)
+
+		code, _, _ = ts.postForm(t, "/account/sessions/revoke/"+matches[0][1], form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, loggedIn(t, other), false)
+		assert.Equal(t, loggedIn(t, ts), true)
+	})
+
+	t.Run("Log out everywhere else", func(t *testing.T) {
+		other.login(t)
+
+		form := url.Values{}
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, _ := ts.postForm(t, "/account/sessions/revoke-others", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, loggedIn(t, other), false)
+		assert.Equal(t, loggedIn(t, ts), true)
+	})
+
+	t.Run("Log out", func(t *testing.T) {
+		other.login(t)
+
+		_, _, body := other.get(t, "/account/view")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := other.postForm(t, "/user/logout", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		// The session is no longer listed.
+		_, _, body = ts.get(t, "/account/view")
+		assert.Equal(t, len(sessionRevokeRX.FindAllStringSubmatch(body, -1)), 0)
+	})
+
+	t.Run("Password update", func(t *testing.T) {
+		other.login(t)
+
+		form := url.Values{}
+		form.Add("current", "pass")
+		form.Add("new", "newpassword")
+		form.Add("confirm", "newpassword")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, _ := ts.postForm(t, "/account/password/update", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, loggedIn(t, other), false)
+		assert.Equal(t, loggedIn(t, ts), true)
 	})
 }
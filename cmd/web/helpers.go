@@ -1,14 +1,24 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/gustavodiasag/notebox/internal/models"
+	"github.com/gustavodiasag/notebox/internal/validator"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	qrcode "github.com/skip2/go-qrcode"
 )
 
 // Writes an error message and stack trace for the current goroutine,
@@ -37,12 +47,79 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// Decodes the JSON request body into `dst`, which must hold a single value with no unknown fields.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	// Limits the size of the request body to 1MB.
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains malformed JSON")
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains an invalid value for the %q field", typeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return errors.New("body must not be larger than 1MB")
+		default:
+			return err
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// Encodes the data as JSON and sends it with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// Runs `fn` in a goroutine which is waited for before the application exits, logging any panic
+// instead of crashing the whole application.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Printf("%v\n%s", err, debug.Stack())
+			}
+		}()
+
+		fn()
+	}()
+}
+
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
 
@@ -75,3 +152,277 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// Returns the ID of the user making the request, be it through the session or an API token, or zero
+// if the request isn't authenticated.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// Reports whether the note can be seen by the user making the request, since private notes are
+// only visible to their authors.
+func (app *application) canView(r *http.Request, note *models.Note) bool {
+	if note.Visibility != models.VisibilityPrivate {
+		return true
+	}
+
+	return note.UserID == app.authenticatedUserID(r)
+}
+
+// Durations which can be chosen for a note to expire in, other than never or a specific moment.
+var expiryDurations = map[string]time.Duration{
+	"1h":   time.Hour,
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+const (
+	expiryNever = "never"
+	expiryAt    = "at"
+	// Layout of the values sent by `datetime-local` inputs, which are taken as UTC.
+	expiryLayout = "2006-01-02T15:04"
+)
+
+// Checks the expiry chosen in a form, which is either one of `expiryDurations`, `expiryNever` or
+// `expiryAt`, in which case the moment is given by `at`. Returns when the note expires, being the
+// zero time if it never does.
+func checkExpiry(v *validator.Validator, choice, at string) time.Time {
+	if d, ok := expiryDurations[choice]; ok {
+		return time.Now().Add(d)
+	}
+
+	switch choice {
+	case expiryNever:
+		return time.Time{}
+	case expiryAt:
+		t, err := time.Parse(expiryLayout, at)
+		v.CheckField(err == nil && t.After(time.Now()), "expires", "Field must be a future date and time")
+		return t
+	default:
+		v.AddFieldError("expires", "Field must be a permitted expiry")
+		return time.Time{}
+	}
+}
+
+// Time during which a protected note can be read without its password once unlocked.
+const unlockDuration = 30 * time.Minute
+
+// Session key under which the moment until which the note is unlocked is stored.
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlockedNote:%d", id)
+}
+
+// Reports whether the content of the note can be shown, which, for protected notes, requires them
+// to have been recently unlocked in the session. Authors never need to unlock their notes.
+func (app *application) isUnlocked(r *http.Request, note *models.Note) bool {
+	if !note.Protected || note.UserID == app.authenticatedUserID(r) {
+		return true
+	}
+
+	return time.Now().Unix() < app.sessionManager.GetInt64(r.Context(), unlockKey(note.ID))
+}
+
+// Sends a JSON response describing the error to API clients.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Sends the field errors of an invalid form to API clients.
+func (app *application) apiValidationError(w http.ResponseWriter, v validator.Validator) {
+	app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": v.FieldErrors})
+}
+
+// Retrieves the note identified by the `slug` route parameter, as long as it can be seen by the
+// user making the request. Otherwise, `models.ErrNoRecord` is returned.
+func (app *application) noteBySlug(r *http.Request) (*models.Note, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	slug := params.ByName("slug")
+	if !validator.Matches(slug, validator.SlugRX) {
+		return nil, models.ErrNoRecord
+	}
+
+	note, err := app.notes.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	// The existence of private notes isn't disclosed to anyone but their authors.
+	if !app.canView(r, note) {
+		return nil, models.ErrNoRecord
+	}
+
+	return note, nil
+}
+
+// Same as `noteBySlug`, although sending the corresponding error response and returning `false`
+// when the note can't be retrieved.
+func (app *application) viewableNote(w http.ResponseWriter, r *http.Request) (*models.Note, bool) {
+	note, err := app.noteBySlug(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return note, true
+}
+
+// Retrieves the note identified by the `id` route parameter, as long as it belongs to the
+// authenticated user. Otherwise, the corresponding error response is sent and `false` is returned.
+// Notes of other users are reported as missing, so that which sequential IDs exist isn't disclosed.
+func (app *application) ownedNote(w http.ResponseWriter, r *http.Request) (*models.Note, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	note, err := app.notes.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if note.UserID != app.authenticatedUserID(r) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return note, true
+}
+
+// Splits a comma-separated list of tags, converting them to lowercase and dropping any blank or
+// repeated entries.
+func parseTags(s string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Issuer shown by authenticator apps next to the codes of the account.
+const totpIssuer = "Notebox"
+
+// Renders, as a PNG image, the QR code which authenticator apps scan to be set up with the secret.
+func totpQRCode(email, secret string) ([]byte, error) {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + totpIssuer + ":" + email,
+		RawQuery: url.Values{
+			"secret":    {secret},
+			"issuer":    {totpIssuer},
+			"algorithm": {"SHA1"},
+			"digits":    {"6"},
+			"period":    {"30"},
+		}.Encode(),
+	}
+
+	return qrcode.Encode(u.String(), qrcode.Medium, 256)
+}
+
+// Returns the IP address the request comes from, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Describes how long a user has to wait, rounded up to the next second or minute.
+func fmtWait(d time.Duration) string {
+	if d <= time.Minute {
+		seconds := int((d + time.Second - 1) / time.Second)
+		if seconds <= 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+// Counts a login attempt against both the IP address and the account, unless either is blocked,
+// returning how long they have to wait in that case. The attempt counts as a failed one until it's
+// released by `releaseLogin`.
+func (app *application) attemptLogin(r *http.Request, accountKey string) time.Duration {
+	ip := clientIP(r)
+
+	if wait := app.ipBackoff.Attempt(ip); wait > 0 {
+		return wait
+	}
+
+	if wait := app.accountBackoff.Attempt(accountKey); wait > 0 {
+		// Attempts which aren't made don't count against the IP address.
+		app.ipBackoff.Release(ip)
+		return wait
+	}
+
+	return 0
+}
+
+// Gives back a login attempt which didn't fail.
+func (app *application) releaseLogin(r *http.Request, accountKey string) {
+	app.ipBackoff.Release(clientIP(r))
+	app.accountBackoff.Release(accountKey)
+}
+
+// Audits any lockout resulting from a failed login, which was already counted by `attemptLogin`.
+func (app *application) failLogin(r *http.Request, accountKey, subject string) {
+	ip := clientIP(r)
+
+	if d := app.ipBackoff.Wait(ip); d > 0 {
+		app.recordLockout(models.LockoutIP, ip, ip, d)
+	}
+	if d := app.accountBackoff.Wait(accountKey); d > 0 {
+		app.recordLockout(models.LockoutAccount, subject, ip, d)
+	}
+}
+
+// Stores the audit record of the lockout in the background, since the response shouldn't wait for
+// it.
+func (app *application) recordLockout(kind, subject, ip string, d time.Duration) {
+	until := time.Now().Add(d)
+
+	app.infoLog.Printf("lockout: %s %s locked out until %s after failed logins from %s", kind, subject, until.UTC().Format(time.RFC3339), ip)
+
+	app.background(func() {
+		err := app.lockouts.Insert(kind, subject, ip, until)
+		if err != nil {
+			app.errorLog.Printf("recording lockout: %v", err)
+		}
+	})
+}
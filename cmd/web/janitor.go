@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Settings of the background worker which permanently deletes expired and trashed notes.
+type janitorConfig struct {
+	interval time.Duration
+	// Maximum number of notes deleted by each query, keeping the time spent holding locks short.
+	batchSize int
+	// Time during which notes are kept after expiring.
+	grace time.Duration
+	// Time during which notes are kept in the trash.
+	retention time.Duration
+}
+
+// Purges expired notes, as well as the ones left in the trash for too long, right away and then
+// every `cfg.interval` until the context is cancelled.
+func (app *application) runJanitor(ctx context.Context, cfg janitorConfig) {
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	// Otherwise, nothing would be purged until a whole interval after every restart.
+	app.purge(ctx, cfg)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purge(ctx, cfg)
+		}
+	}
+}
+
+// Runs a single pass of the janitor, logging what was purged.
+func (app *application) purge(ctx context.Context, cfg janitorConfig) {
+	n, err := app.purgeExpired(ctx, cfg)
+	if err != nil {
+		app.errorLog.Printf("janitor: %v", err)
+	}
+	if n > 0 {
+		app.infoLog.Printf("janitor: purged %d expired notes", n)
+	}
+
+	n, err = app.purgeDeleted(ctx, cfg)
+	if err != nil {
+		app.errorLog.Printf("janitor: %v", err)
+	}
+	if n > 0 {
+		app.infoLog.Printf("janitor: purged %d notes from the trash", n)
+	}
+}
+
+// Deletes every note past its grace period, returning how many were deleted.
+func (app *application) purgeExpired(ctx context.Context, cfg janitorConfig) (int, error) {
+	return purgeInBatches(ctx, cfg.batchSize, func(limit int) (int, error) {
+		return app.notes.PurgeExpired(cfg.grace, limit)
+	})
+}
+
+// Deletes every note left in the trash past its retention, returning how many were deleted.
+func (app *application) purgeDeleted(ctx context.Context, cfg janitorConfig) (int, error) {
+	return purgeInBatches(ctx, cfg.batchSize, func(limit int) (int, error) {
+		return app.notes.PurgeDeleted(cfg.retention, limit)
+	})
+}
+
+// Calls `purge` until it deletes less than a whole batch of notes, meaning there's nothing left to
+// delete, returning how many were deleted in total.
+func purgeInBatches(ctx context.Context, batchSize int, purge func(limit int) (int, error)) (int, error) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := purge(batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < batchSize {
+			break
+		}
+	}
+
+	return total, nil
+}
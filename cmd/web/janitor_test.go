@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+	"github.com/gustavodiasag/notebox/internal/models/mocks"
+)
+
+// Note model holding a number of expired notes, purged as requested.
+type expiredNoteModel struct {
+	mocks.NoteModel
+	expired int
+	calls   int
+}
+
+func (m *expiredNoteModel) PurgeExpired(grace time.Duration, limit int) (int, error) {
+	m.calls++
+
+	n := min(m.expired, limit)
+	m.expired -= n
+
+	return n, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	app := newTestApplication(t)
+
+	notes := &expiredNoteModel{expired: 250}
+	app.notes = notes
+
+	n, err := app.purgeExpired(context.Background(), janitorConfig{batchSize: 100})
+
+	assert.NilError(t, err)
+	assert.Equal(t, n, 250)
+	assert.Equal(t, notes.expired, 0)
+	assert.Equal(t, notes.calls, 3)
+}
+
+func TestRunJanitor(t *testing.T) {
+	app := newTestApplication(t)
+
+	notes := &expiredNoteModel{expired: 10}
+	app.notes = notes
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		app.runJanitor(ctx, janitorConfig{interval: time.Millisecond, batchSize: 100})
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor didn't stop once its context was cancelled")
+	}
+
+	assert.Equal(t, notes.expired, 0)
+}
+
+func TestRunJanitorAtStartup(t *testing.T) {
+	app := newTestApplication(t)
+
+	notes := &expiredNoteModel{expired: 10}
+	app.notes = notes
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		// The interval is long enough that only the purge at startup can run.
+		app.runJanitor(ctx, janitorConfig{interval: time.Hour, batchSize: 100})
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, notes.expired, 0)
+}
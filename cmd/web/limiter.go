@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Maximum number of keys tracked by a limiter or a backoff. Keys can be chosen by clients, such as
+// the email addresses logins are attempted with, so they must not be able to grow the map without
+// bound. Once full, an arbitrary key is forgotten to make room for a new one.
+const maxLimiterKeys = 100000
+
+// Interval between the removals of the expired keys of the limiters and backoffs.
+const limiterSweepInterval = time.Minute
+
+// Keeps track of the failed attempts made against each key, such as a note ID, blocking a key once
+// it reaches `max` failures within `window`. Attempts are only kept in memory, so they are lost when
+// the application restarts.
+type limiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*failures
+}
+
+type failures struct {
+	count int
+	// Moment from which the failures are forgotten.
+	reset time.Time
+}
+
+func newLimiter(max int, window time.Duration) *limiter {
+	return &limiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string]*failures),
+	}
+}
+
+// Reports whether another attempt can be made against the key, counting it if so. Attempts are
+// counted as failures right away, so that concurrent ones can't all be made before any of them
+// fails, the failures being forgotten with `Reset` once an attempt succeeds.
+func (l *limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	f, ok := l.failures[key]
+	if !ok || now.After(f.reset) {
+		if !ok && len(l.failures) >= maxLimiterKeys {
+			evictOne(l.failures)
+		}
+
+		f = &failures{reset: now.Add(l.window)}
+		l.failures[key] = f
+	}
+
+	if f.count >= l.max {
+		return false
+	}
+
+	f.count++
+
+	return true
+}
+
+// Forgets the failed attempts made against the key.
+func (l *limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, key)
+}
+
+// Removes the keys whose failures were forgotten.
+func (l *limiter) Sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	for k, f := range l.failures {
+		if now.After(f.reset) {
+			delete(l.failures, k)
+		}
+	}
+}
+
+// Keeps track of the failed attempts made against each key, such as an IP address, blocking a key
+// for exponentially longer periods once it fails more than `free` times. Failures are forgotten once
+// `window` passes without any. Like `limiter`, attempts are only kept in memory.
+type backoff struct {
+	mu      sync.Mutex
+	free    int
+	base    time.Duration
+	max     time.Duration
+	window  time.Duration
+	entries map[string]*backoffEntry
+}
+
+type backoffEntry struct {
+	count int
+	last  time.Time
+	// Moment until which the key is blocked.
+	until time.Time
+}
+
+func newBackoff(free int, base, max, window time.Duration) *backoff {
+	return &backoff{
+		free:    free,
+		base:    base,
+		max:     max,
+		window:  window,
+		entries: make(map[string]*backoffEntry),
+	}
+}
+
+// Returns how long the key remains blocked, being zero if another attempt can be made.
+func (b *backoff) Wait(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	e, ok := b.entries[key]
+	if !ok || !now.Before(e.until) {
+		return 0
+	}
+
+	return e.until.Sub(now)
+}
+
+// Counts an attempt against the key unless it's blocked, returning how long it remains blocked for
+// if so, being zero if the attempt can be made. Attempts are counted as failures right away, so
+// that concurrent ones can't all be made before any of them fails, the successful ones being given
+// back with `Release`.
+func (b *backoff) Attempt(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	e, ok := b.entries[key]
+	if !ok || b.expired(e, now) {
+		if !ok && len(b.entries) >= maxLimiterKeys {
+			evictOne(b.entries)
+		}
+
+		e = &backoffEntry{}
+		b.entries[key] = e
+	}
+
+	if now.Before(e.until) {
+		return e.until.Sub(now)
+	}
+
+	e.count++
+	e.last = now
+	e.until = now.Add(b.delay(e.count))
+
+	return 0
+}
+
+// Gives back an attempt counted by `Attempt` which succeeded, lifting the block it caused.
+func (b *backoff) Release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.entries[key]
+	if !ok || e.count == 0 {
+		return
+	}
+
+	e.count--
+	e.until = e.last.Add(b.delay(e.count))
+}
+
+// Returns how long the key is blocked for after `count` failures.
+func (b *backoff) delay(count int) time.Duration {
+	if count <= b.free {
+		return 0
+	}
+
+	// Doubles for each failure past the free ones, up to `max`.
+	d := b.base
+	for i := b.free + 1; i < count && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+
+	return d
+}
+
+// Forgets the failed attempts made against the key.
+func (b *backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+// Removes the keys whose failures were forgotten.
+func (b *backoff) Sweep() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	for k, e := range b.entries {
+		if b.expired(e, now) {
+			delete(b.entries, k)
+		}
+	}
+}
+
+// Reports whether the failures of the entry are forgotten, `window` having passed since the last
+// one without the key being blocked anymore.
+func (b *backoff) expired(e *backoffEntry, now time.Time) bool {
+	return now.After(e.last.Add(b.window)) && !now.Before(e.until)
+}
+
+// Removes an arbitrary key from the map, relying on the random order maps are iterated in.
+func evictOne[V any](m map[string]V) {
+	for k := range m {
+		delete(m, k)
+		return
+	}
+}
+
+// Removes the expired keys of the limiters and backoffs every `limiterSweepInterval`, until the
+// context is cancelled. Sweeping them apart from the attempts keeps each attempt from going through
+// every key while holding the lock.
+func (app *application) runLimiterSweeper(ctx context.Context) {
+	ticker := time.NewTicker(limiterSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.unlockLimiter.Sweep()
+			app.ipBackoff.Sweep()
+			app.accountBackoff.Sweep()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, time.Hour)
+
+	assert.Equal(t, l.Allow("1"), true)
+	assert.Equal(t, l.Allow("1"), true)
+	assert.Equal(t, l.Allow("1"), false)
+
+	// Keys are limited independently.
+	assert.Equal(t, l.Allow("2"), true)
+
+	l.Reset("1")
+	assert.Equal(t, l.Allow("1"), true)
+
+	// Failures are forgotten once the window elapses.
+	l = newLimiter(1, -time.Second)
+
+	assert.Equal(t, l.Allow("1"), true)
+	assert.Equal(t, l.Allow("1"), true)
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	l := newLimiter(5, time.Hour)
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Allow("1") {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, allowed.Load(), int32(5))
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(2, time.Minute, 5*time.Minute, time.Hour)
+
+	// The free attempts don't block the key.
+	assert.Equal(t, b.Attempt("1"), time.Duration(0))
+	assert.Equal(t, b.Attempt("1"), time.Duration(0))
+	assert.Equal(t, b.Wait("1"), time.Duration(0))
+
+	// Then each attempt blocks the key for twice as long as the previous one, up to the maximum.
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+
+	for _, d := range want {
+		b.entries["1"].until = time.Time{}
+
+		assert.Equal(t, b.Attempt("1"), time.Duration(0))
+
+		if wait := b.Wait("1"); wait <= d-time.Second || wait > d {
+			t.Errorf("got: %v; want a wait of up to %v", wait, d)
+		}
+	}
+
+	// Blocked attempts aren't counted.
+	count := b.entries["1"].count
+	if wait := b.Attempt("1"); wait == 0 {
+		t.Error("got: 0; want a wait")
+	}
+	assert.Equal(t, b.entries["1"].count, count)
+
+	// Keys are blocked independently.
+	assert.Equal(t, b.Wait("2"), time.Duration(0))
+
+	b.Reset("1")
+	assert.Equal(t, b.Wait("1"), time.Duration(0))
+	assert.Equal(t, b.Attempt("1"), time.Duration(0))
+}
+
+func TestBackoffRelease(t *testing.T) {
+	b := newBackoff(1, time.Minute, 5*time.Minute, time.Hour)
+
+	assert.Equal(t, b.Attempt("1"), time.Duration(0))
+	assert.Equal(t, b.Attempt("1"), time.Duration(0))
+	if wait := b.Wait("1"); wait == 0 {
+		t.Error("got: 0; want a wait")
+	}
+
+	// A successful attempt lifts the block it caused.
+	b.Release("1")
+	assert.Equal(t, b.Wait("1"), time.Duration(0))
+	assert.Equal(t, b.entries["1"].count, 1)
+}
+
+func TestBackoffConcurrent(t *testing.T) {
+	b := newBackoff(5, time.Minute, 5*time.Minute, time.Hour)
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Attempt("1") == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only the free attempts and the one which blocks the key get through.
+	assert.Equal(t, allowed.Load(), int32(6))
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(1, -time.Second)
+	l.Allow("1")
+
+	b := newBackoff(1, time.Minute, 5*time.Minute, -time.Second)
+	b.Attempt("1")
+
+	l.Sweep()
+	b.Sweep()
+
+	assert.Equal(t, len(l.failures), 0)
+	assert.Equal(t, len(b.entries), 0)
+}
+
+func TestLimiterMaxKeys(t *testing.T) {
+	l := newLimiter(1, time.Hour)
+	b := newBackoff(1, time.Minute, 5*time.Minute, time.Hour)
+
+	for i := 0; i < maxLimiterKeys+10; i++ {
+		key := strconv.Itoa(i)
+		l.Allow(key)
+		b.Attempt(key)
+	}
+
+	assert.Equal(t, len(l.failures), maxLimiterKeys)
+	assert.Equal(t, len(b.entries), maxLimiterKeys)
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/gustavodiasag/notebox/internal/mailer"
 	"github.com/gustavodiasag/notebox/internal/models"
 
 	"github.com/go-playground/form/v4"
@@ -24,9 +32,23 @@ type application struct {
 	infoLog        *log.Logger
 	notes          models.NoteModelInterface
 	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
+	totp           models.TOTPModelInterface
+	lockouts       models.LockoutModelInterface
+	sessions       models.SessionModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	unlockLimiter  *limiter
+	// Failed logins are throttled both per IP address and per account.
+	ipBackoff      *backoff
+	accountBackoff *backoff
+	trashRetention time.Duration
+	mailer         mailer.Mailer
+	// URL the application is reached at, used to build the links sent by email.
+	baseURL string
+	// Tracks the goroutines started by `background`, which are waited for before exiting.
+	wg sync.WaitGroup
 }
 
 func main() {
@@ -34,11 +56,46 @@ func main() {
 	dsn := flag.String("dsn", "web:pass@/notebox?parseTime=true", "MySQL data source name")
 	debug := flag.Bool("debug", false, "Enter debug mode")
 
+	var janitor janitorConfig
+	flag.DurationVar(&janitor.interval, "janitor-interval", 10*time.Minute, "Interval between purges of expired notes")
+	flag.IntVar(&janitor.batchSize, "janitor-batch-size", 100, "Maximum number of expired notes deleted at once")
+	flag.DurationVar(&janitor.grace, "janitor-grace", 24*time.Hour, "Time during which expired notes are kept before being purged")
+	flag.DurationVar(&janitor.retention, "trash-retention", 30*24*time.Hour, "Time during which deleted notes are kept in the trash")
+
+	baseURL := flag.String("base-url", "https://localhost:4000", "URL the application is reached at")
+
+	var smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+	flag.StringVar(&smtp.host, "smtp-host", "", "SMTP server host, emails being written to the standard output if empty")
+	flag.IntVar(&smtp.port, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&smtp.username, "smtp-username", "", "SMTP server username")
+	flag.StringVar(&smtp.password, "smtp-password", "", "SMTP server password")
+	flag.StringVar(&smtp.sender, "smtp-sender", "Notebox <no-reply@notebox.local>", "Address emails are sent from")
+
+	totpKey := flag.String("totp-key", "", "Hex-encoded 32-byte key encrypting the secrets of authenticator apps, as generated by openssl rand -hex 32, two-factor authentication being unavailable if empty")
+
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	if janitor.interval <= 0 || janitor.batchSize <= 0 || janitor.grace < 0 || janitor.retention < 0 {
+		errorLog.Fatal("janitor-interval and janitor-batch-size must be positive, janitor-grace and trash-retention can't be negative")
+	}
+
+	key, err := hex.DecodeString(*totpKey)
+	if err != nil || (len(key) != 0 && len(key) != 32) {
+		errorLog.Fatal("totp-key must be a hex-encoded 32-byte key")
+	}
+	if len(key) == 0 {
+		infoLog.Print("No totp-key given, two-factor authentication can't be set up or used")
+	}
+
 	db, err := openDB(*dsn)
 	if err != nil {
 		errorLog.Fatal(err)
@@ -50,6 +107,14 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	var m mailer.Mailer = mailer.NewLog(os.Stdout, smtp.sender)
+	if smtp.host != "" {
+		m, err = mailer.NewSMTP(smtp.host, smtp.port, smtp.username, smtp.password, smtp.sender)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
@@ -64,9 +129,19 @@ func main() {
 		infoLog:        infoLog,
 		notes:          &models.NoteModel{DB: db},
 		users:          &models.UserModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		totp:           &models.TOTPModel{DB: db, Key: key},
+		lockouts:       &models.LockoutModel{DB: db},
+		sessions:       &models.SessionModel{DB: db, Lifetime: sessionManager.Lifetime},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newLimiter(5, 15*time.Minute),
+		ipBackoff:      newBackoff(20, 30*time.Second, 15*time.Minute, time.Hour),
+		accountBackoff: newBackoff(5, 30*time.Second, 15*time.Minute, time.Hour),
+		trashRetention: janitor.retention,
+		mailer:         m,
+		baseURL:        strings.TrimSuffix(*baseURL, "/"),
 	}
 	// Used so that only elliptic curves with assembly implementations are used.
 	tlsConfig := &tls.Config{
@@ -83,9 +158,48 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Cancelled once the application is asked to stop, shutting down the server and the workers.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.runJanitor(ctx, janitor)
+	}()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.runLimiterSweeper(ctx)
+	}()
+
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("Shutting down server")
+
+		// Requests in progress are given some time to complete.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
 	infoLog.Printf("Starting server on %s", *addr)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	if err = <-shutdownErr; err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Waits for the background workers as well as any email still being sent.
+	app.wg.Wait()
+	infoLog.Print("Server stopped")
 }
 
 func openDB(dsn string) (*sql.DB, error) {
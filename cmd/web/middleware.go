@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/gustavodiasag/notebox/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -58,8 +62,18 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Sessions revoked from another device are no longer indexed.
+		if exists {
+			exists, err = app.checkSession(r, id)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
@@ -67,6 +81,43 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// Authenticates API clients through a personal token sent in the `Authorization` header, taking
+// precedence over the session. Requests with an invalid token are rejected rather than treated as
+// anonymous, so that clients notice it.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		plaintext, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			app.invalidTokenResponse(w)
+			return
+		}
+
+		token, err := app.tokens.Authenticate(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.invalidTokenResponse(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, tokenScopeContextKey, token.Scope)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !app.isAuthenticated(r) {
@@ -81,6 +132,35 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// Same as `requireAuthentication`, although responding to unauthenticated API clients with a JSON
+// error instead of redirecting them to the login page.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiError(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Rejects requests authenticated through read-only tokens. Requests authenticated through the
+// session aren't restricted.
+func (app *application) requireWriteScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := r.Context().Value(tokenScopeContextKey).(string)
+		if ok && scope != models.ScopeWrite {
+			app.apiError(w, http.StatusForbidden, "your token doesn't have the write scope")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
@@ -91,3 +171,19 @@ func noSurf(next http.Handler) http.Handler {
 
 	return csrfHandler
 }
+
+// Same as `noSurf`, although exempting requests carrying an `Authorization` header. Browsers never
+// attach it on their own, so those requests can't be forged, and API clients have no CSRF cookie.
+func apiNoSurf(next http.Handler) http.Handler {
+	csrfHandler := nosurf.New(next)
+	csrfHandler.SetBaseCookie(http.Cookie{
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+	})
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") != ""
+	})
+
+	return csrfHandler
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Description of a route in the OpenAPI document. Parameters and bodies are described through the
+// structs their handlers decode them into, so that the document can't drift apart from them.
+type operation struct {
+	summary string
+	tag     string
+	// Whether the route requires the user to be authenticated.
+	auth bool
+	// Struct whose `form` tags describe the query parameters.
+	query any
+	// Struct whose `form` tags describe the form-encoded body, which also carries the CSRF token.
+	form any
+	// Struct whose `json` tags describe the JSON body.
+	body any
+	// Descriptions of the possible responses, keyed by status code.
+	responses map[int]string
+	// Value whose `json` tags describe the body of successful responses.
+	result any
+	// Media type of successful responses, which defaults to HTML, or JSON for the API.
+	contentType string
+}
+
+// Used by the routes which take no parameters other than the CSRF token.
+type emptyForm struct{}
+
+// Describes every route registered in `router`. Any route missing from here makes `TestOpenAPI`
+// fail.
+var operations = map[route]operation{
+	{http.MethodGet, "/static/*filepath"}: {
+		summary:     "Serve a static asset",
+		tag:         "misc",
+		responses:   map[int]string{200: "The asset", 404: "No such asset"},
+		contentType: "application/octet-stream",
+	},
+	{http.MethodGet, "/health_check"}: {
+		summary:     "Check that the application is up",
+		tag:         "misc",
+		responses:   map[int]string{200: "The application is up"},
+		contentType: "text/plain",
+	},
+	{http.MethodGet, "/"}: {
+		summary:   "Show the latest notes",
+		tag:       "notes",
+		responses: map[int]string{200: "The home page"},
+	},
+	{http.MethodGet, "/about"}: {
+		summary:   "Show the about page",
+		tag:       "misc",
+		responses: map[int]string{200: "The about page"},
+	},
+	{http.MethodGet, "/search"}: {
+		summary:   "Search public notes",
+		tag:       "notes",
+		query:     searchForm{},
+		responses: map[int]string{200: "The matching notes", 400: "Invalid page"},
+	},
+	{http.MethodGet, "/notes"}: {
+		summary:   "List public notes",
+		tag:       "notes",
+		query:     noteListForm{},
+		responses: map[int]string{200: "A page of notes", 400: "Invalid query"},
+	},
+	{http.MethodGet, "/tag/:name"}: {
+		summary:   "List public notes with a tag",
+		tag:       "notes",
+		responses: map[int]string{200: "The notes with the tag", 404: "Invalid tag"},
+	},
+	{http.MethodGet, "/n/:slug"}: {
+		summary:   "Show a note",
+		tag:       "notes",
+		responses: map[int]string{200: "The note, or the page to unlock or burn it", 404: "No such note"},
+	},
+	{http.MethodPost, "/n/:slug"}: {
+		summary:   "Reveal a burn-after-reading note, destroying it",
+		tag:       "notes",
+		form:      emptyForm{},
+		responses: map[int]string{200: "The note", 303: "The note can't be burnt", 404: "No such note"},
+	},
+	{http.MethodPost, "/n/:slug/unlock"}: {
+		summary:   "Unlock a password-protected note",
+		tag:       "notes",
+		form:      noteUnlockForm{},
+		responses: map[int]string{303: "The note was unlocked", 404: "No such note", 422: "Incorrect password", 429: "Too many attempts"},
+	},
+	{http.MethodGet, "/n/:slug/history"}: {
+		summary:   "Show the revisions of a note",
+		tag:       "notes",
+		query:     noteHistoryForm{},
+		responses: map[int]string{200: "The revisions", 303: "The note is locked", 404: "No such note, or it is burnt after reading or encrypted"},
+	},
+	{http.MethodGet, "/note/view/:id"}: {
+		summary:   "Redirect to a note by its ID",
+		tag:       "notes",
+		responses: map[int]string{301: "The URL of the note", 404: "No such note"},
+	},
+	{http.MethodGet, "/user/signup"}: {
+		summary:   "Show the signup form",
+		tag:       "users",
+		responses: map[int]string{200: "The signup form"},
+	},
+	{http.MethodPost, "/user/signup"}: {
+		summary:   "Create an account",
+		tag:       "users",
+		form:      userSignupForm{},
+		responses: map[int]string{303: "The account was created and a verification link sent", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/user/login"}: {
+		summary:   "Show the login form",
+		tag:       "users",
+		responses: map[int]string{200: "The login form"},
+	},
+	{http.MethodPost, "/user/login"}: {
+		summary:   "Log in",
+		tag:       "users",
+		form:      userLoginForm{},
+		responses: map[int]string{303: "The user was logged in, or asked for a two-factor authentication code", 422: "Invalid credentials or unverified email address", 429: "Too many failed attempts from the address or against the account"},
+	},
+	{http.MethodGet, "/user/login/totp"}: {
+		summary:   "Show the form asking for a two-factor authentication code",
+		tag:       "users",
+		responses: map[int]string{200: "The form", 303: "The password wasn't checked or the login expired"},
+	},
+	{http.MethodPost, "/user/login/totp"}: {
+		summary:   "Complete the login with the code of an authenticator app or a recovery code",
+		tag:       "users",
+		form:      userTOTPForm{},
+		responses: map[int]string{303: "The user was logged in, or has to log in again", 422: "Invalid code", 429: "Too many failed attempts from the address or against the account"},
+	},
+	{http.MethodGet, "/user/verify"}: {
+		summary:   "Show the form to request a new verification link",
+		tag:       "users",
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/user/verify"}: {
+		summary:   "Send a new verification link to an unverified account",
+		tag:       "users",
+		form:      userVerifyForm{},
+		responses: map[int]string{303: "The link was sent if the account exists", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/user/verify/:token"}: {
+		summary:   "Verify the email address of an account",
+		tag:       "users",
+		responses: map[int]string{303: "The address was verified", 404: "Invalid or expired token"},
+	},
+	{http.MethodGet, "/user/password/forgot"}: {
+		summary:   "Show the form to request a password reset",
+		tag:       "users",
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/user/password/forgot"}: {
+		summary:   "Send a link to reset the password of an account",
+		tag:       "users",
+		form:      passwordForgotForm{},
+		responses: map[int]string{303: "The link was sent if the account exists", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/user/password/reset/:token"}: {
+		summary:   "Show the form to choose a new password",
+		tag:       "users",
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/user/password/reset/:token"}: {
+		summary:   "Reset the password, logging the user out everywhere",
+		tag:       "users",
+		form:      passwordResetForm{},
+		responses: map[int]string{303: "The password was reset", 404: "Invalid or expired token", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/account/view"}: {
+		summary:   "Show the account of the user, along with their API tokens and sessions",
+		tag:       "users",
+		auth:      true,
+		responses: map[int]string{200: "The account page"},
+	},
+	{http.MethodGet, "/note/create"}: {
+		summary:   "Show the form to create a note",
+		tag:       "notes",
+		auth:      true,
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/note/create"}: {
+		summary:   "Create a note",
+		tag:       "notes",
+		auth:      true,
+		form:      noteCreateForm{},
+		responses: map[int]string{303: "The note was created", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/note/create/encrypted"}: {
+		summary:   "Show the form to create an end-to-end encrypted note",
+		tag:       "notes",
+		auth:      true,
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/note/create/encrypted"}: {
+		summary: "Create an end-to-end encrypted note",
+		tag:     "notes",
+		auth:    true,
+		body:    noteCreateEncryptedForm{},
+		responses: map[int]string{
+			201: "The note was created",
+			400: "Malformed body",
+			422: "Invalid fields",
+		},
+		result: struct {
+			Slug string `json:"slug"`
+			URL  string `json:"url"`
+		}{},
+		contentType: "application/json",
+	},
+	{http.MethodGet, "/note/edit/:id"}: {
+		summary:   "Show the form to edit a note",
+		tag:       "notes",
+		auth:      true,
+		responses: map[int]string{200: "The form", 400: "The note is encrypted", 404: "No such note, or it belongs to another user"},
+	},
+	{http.MethodPost, "/note/edit/:id"}: {
+		summary:   "Edit a note",
+		tag:       "notes",
+		auth:      true,
+		form:      noteEditForm{},
+		responses: map[int]string{303: "The note was edited", 400: "The note is encrypted", 404: "No such note, or it belongs to another user", 422: "Invalid form"},
+	},
+	{http.MethodGet, "/note/extend/:id"}: {
+		summary:   "Show the form to extend the expiry of a note",
+		tag:       "notes",
+		auth:      true,
+		responses: map[int]string{200: "The form", 404: "No such note, or it belongs to another user"},
+	},
+	{http.MethodPost, "/note/extend/:id"}: {
+		summary:   "Extend the expiry of a note",
+		tag:       "notes",
+		auth:      true,
+		form:      noteExtendForm{},
+		responses: map[int]string{303: "The expiry was extended", 404: "No such note, or it belongs to another user", 422: "Invalid form"},
+	},
+	{http.MethodPost, "/note/delete/:id"}: {
+		summary:   "Move a note to the trash",
+		tag:       "notes",
+		auth:      true,
+		form:      emptyForm{},
+		responses: map[int]string{303: "The note was moved to the trash", 404: "No such note, or it belongs to another user"},
+	},
+	{http.MethodPost, "/note/restore/:id"}: {
+		summary:   "Restore a note to one of its revisions",
+		tag:       "notes",
+		auth:      true,
+		form:      noteRestoreForm{},
+		responses: map[int]string{303: "The note was restored", 400: "No such revision", 404: "No such note, or it is encrypted or belongs to another user"},
+	},
+	{http.MethodGet, "/trash"}: {
+		summary:   "Show the notes in the trash",
+		tag:       "notes",
+		auth:      true,
+		responses: map[int]string{200: "The trash"},
+	},
+	{http.MethodPost, "/trash/restore/:id"}: {
+		summary:   "Take a note out of the trash",
+		tag:       "notes",
+		auth:      true,
+		form:      emptyForm{},
+		responses: map[int]string{303: "The note was restored", 404: "No such note in the trash"},
+	},
+	{http.MethodPost, "/trash/purge/:id"}: {
+		summary:   "Permanently delete a note in the trash",
+		tag:       "notes",
+		auth:      true,
+		form:      emptyForm{},
+		responses: map[int]string{303: "The note was deleted", 404: "No such note in the trash"},
+	},
+	{http.MethodPost, "/account/tokens/create"}: {
+		summary:   "Create an API token",
+		tag:       "users",
+		auth:      true,
+		form:      tokenCreateForm{},
+		responses: map[int]string{303: "The token was created", 422: "Invalid form"},
+	},
+	{http.MethodPost, "/account/tokens/revoke/:id"}: {
+		summary:   "Revoke an API token",
+		tag:       "users",
+		auth:      true,
+		form:      emptyForm{},
+		responses: map[int]string{303: "The token was revoked", 404: "No such token"},
+	},
+	{http.MethodPost, "/account/sessions/revoke/:id"}: {
+		summary:   "Log out another session of the user",
+		tag:       "users",
+		auth:      true,
+		responses: map[int]string{303: "The session was logged out", 404: "No such session"},
+	},
+	{http.MethodPost, "/account/sessions/revoke-others"}: {
+		summary:   "Log out every session of the user but the current one",
+		tag:       "users",
+		auth:      true,
+		responses: map[int]string{303: "The other sessions were logged out"},
+	},
+	{http.MethodGet, "/account/totp/setup"}: {
+		summary:   "Show the QR code setting up an authenticator app",
+		tag:       "users",
+		auth:      true,
+		responses: map[int]string{200: "The QR code and the form confirming it", 303: "Two-factor authentication is already enabled"},
+	},
+	{http.MethodPost, "/account/totp/setup"}: {
+		summary:   "Enable two-factor authentication with a code of the app set up",
+		tag:       "users",
+		auth:      true,
+		form:      totpSetupForm{},
+		responses: map[int]string{303: "Two-factor authentication was enabled", 422: "Invalid code"},
+	},
+	{http.MethodGet, "/account/totp/qrcode.png"}: {
+		summary:     "Serve the QR code of the authenticator app being set up",
+		tag:         "users",
+		auth:        true,
+		responses:   map[int]string{200: "The QR code", 404: "No app is being set up"},
+		contentType: "image/png",
+	},
+	{http.MethodGet, "/account/password/update"}: {
+		summary:   "Show the form to change the password",
+		tag:       "users",
+		auth:      true,
+		responses: map[int]string{200: "The form"},
+	},
+	{http.MethodPost, "/account/password/update"}: {
+		summary:   "Change the password",
+		tag:       "users",
+		auth:      true,
+		form:      passwordUpdateForm{},
+		responses: map[int]string{303: "The password was changed and any other session logged out", 422: "Invalid form"},
+	},
+	{http.MethodPost, "/user/logout"}: {
+		summary:   "Log out",
+		tag:       "users",
+		auth:      true,
+		form:      emptyForm{},
+		responses: map[int]string{303: "The user was logged out"},
+	},
+	{http.MethodGet, "/api/v1/notes"}: {
+		summary:   "List public notes",
+		tag:       "api",
+		query:     noteListForm{},
+		responses: map[int]string{200: "A page of notes", 400: "Malformed query", 422: "Invalid query"},
+		result:    apiNotePage{},
+	},
+	{http.MethodGet, "/api/v1/notes/:slug"}: {
+		summary:   "Get a note",
+		tag:       "api",
+		responses: map[int]string{200: "The note", 404: "No such note"},
+		result:    apiNote{},
+	},
+	{http.MethodGet, "/api/v1/search"}: {
+		summary:   "Search public notes",
+		tag:       "api",
+		query:     searchForm{},
+		responses: map[int]string{200: "A page of matching notes", 400: "Malformed query", 422: "Invalid query"},
+		result:    apiNotePage{},
+	},
+	{http.MethodPost, "/api/v1/notes"}: {
+		summary: "Create a note",
+		tag:     "api",
+		auth:    true,
+		body:    apiNoteCreateInput{},
+		responses: map[int]string{
+			201: "The note was created",
+			400: "Malformed body",
+			401: "Unauthenticated",
+			403: "The token can't write",
+			422: "Invalid fields",
+		},
+		result: apiNote{},
+	},
+	{http.MethodPatch, "/api/v1/notes/:slug"}: {
+		summary: "Update a note",
+		tag:     "api",
+		auth:    true,
+		body:    apiNoteUpdateInput{},
+		responses: map[int]string{
+			200: "The updated note",
+			400: "Malformed body, or the note is encrypted",
+			401: "Unauthenticated",
+			403: "The note belongs to another user, or the token can't write",
+			404: "No such note",
+			422: "Invalid fields",
+		},
+		result: apiNote{},
+	},
+	{http.MethodDelete, "/api/v1/notes/:slug"}: {
+		summary: "Move a note to the trash",
+		tag:     "api",
+		auth:    true,
+		responses: map[int]string{
+			204: "The note was moved to the trash",
+			401: "Unauthenticated",
+			403: "The note belongs to another user, or the token can't write",
+			404: "No such note",
+		},
+	},
+	{http.MethodGet, "/api/v1/account"}: {
+		summary:   "Get the account of the user",
+		tag:       "api",
+		auth:      true,
+		responses: map[int]string{200: "The account", 401: "Unauthenticated"},
+		result:    apiAccount{},
+	},
+	{http.MethodGet, "/api/openapi.json"}: {
+		summary:     "Get this document",
+		tag:         "api",
+		responses:   map[int]string{200: "The OpenAPI document"},
+		contentType: "application/json",
+	},
+}
+
+// Serves the OpenAPI document describing the routes registered in `rr`.
+func (app *application) openAPI(rr *routeRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.writeJSON(w, http.StatusOK, newOpenAPIDocument(rr.routes))
+	}
+}
+
+func newOpenAPIDocument(routes []route) map[string]any {
+	paths := map[string]map[string]any{}
+
+	for _, rt := range routes {
+		op, ok := operations[rt]
+		if !ok {
+			continue
+		}
+
+		path, params := openAPIPath(rt.path)
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+
+		paths[path][strings.ToLower(rt.method)] = op.describe(rt, params)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Notebox",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"cookieAuth": map[string]any{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "session",
+				},
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Personal API token, created from the account page",
+				},
+			},
+		},
+	}
+}
+
+// Converts the path pattern of the router into the one used by OpenAPI, returning the parameters
+// taken from the path along with it.
+func openAPIPath(pattern string) (string, []map[string]any) {
+	segments := strings.Split(pattern, "/")
+	params := []map[string]any{}
+
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			continue
+		}
+
+		name := s[1:]
+		schema := map[string]any{"type": "string"}
+		// Only notes and tokens are identified by sequential IDs.
+		if name == "id" {
+			schema = map[string]any{"type": "integer", "minimum": 1}
+		}
+
+		segments[i] = "{" + name + "}"
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func (op operation) describe(rt route, params []map[string]any) map[string]any {
+	isAPI := strings.HasPrefix(rt.path, "/api/")
+
+	contentType := op.contentType
+	if contentType == "" {
+		contentType = "text/html"
+		if isAPI {
+			contentType = "application/json"
+		}
+	}
+
+	if op.query != nil {
+		props := schemaOf(reflect.TypeOf(op.query), "form")["properties"].(map[string]any)
+		for _, name := range sortedKeys(props) {
+			params = append(params, map[string]any{
+				"name":   name,
+				"in":     "query",
+				"schema": props[name],
+			})
+		}
+	}
+	// JSON bodies can't carry the CSRF token, which is only checked for sessions.
+	if op.body != nil || (isAPI && rt.method != http.MethodGet) {
+		params = append(params, map[string]any{
+			"name":        "X-CSRF-Token",
+			"in":          "header",
+			"description": "CSRF token, required when authenticated through the session cookie",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+
+	d := map[string]any{
+		"summary":   op.summary,
+		"tags":      []string{op.tag},
+		"responses": op.describeResponses(contentType, isAPI),
+	}
+
+	if len(params) > 0 {
+		d["parameters"] = params
+	}
+
+	if op.form != nil {
+		schema := schemaOf(reflect.TypeOf(op.form), "form")
+		schema["properties"].(map[string]any)["csrf_token"] = map[string]any{"type": "string"}
+		schema["required"] = []string{"csrf_token"}
+
+		d["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/x-www-form-urlencoded": map[string]any{"schema": schema},
+			},
+		}
+	}
+	if op.body != nil {
+		d["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(op.body), "json")},
+			},
+		}
+	}
+
+	if op.auth {
+		security := []map[string][]string{{"cookieAuth": {}}}
+		if isAPI {
+			security = append([]map[string][]string{{"bearerAuth": {}}}, security...)
+		}
+		d["security"] = security
+	}
+
+	return d
+}
+
+func (op operation) describeResponses(contentType string, isAPI bool) map[string]any {
+	responses := map[string]any{}
+
+	for status, description := range op.responses {
+		rs := map[string]any{"description": description}
+
+		switch {
+		case status == http.StatusNoContent || (status >= 300 && status < 400):
+			// Responses without a body.
+		case status < 300:
+			schema := map[string]any{"type": "string"}
+			if op.result != nil {
+				schema = schemaOf(reflect.TypeOf(op.result), "json")
+			} else if contentType == "application/json" {
+				schema = map[string]any{"type": "object"}
+			}
+			rs["content"] = map[string]any{contentType: map[string]any{"schema": schema}}
+		case contentType == "application/json" || isAPI:
+			rs["content"] = map[string]any{"application/json": map[string]any{"schema": errorSchema(status)}}
+		default:
+			rs["content"] = map[string]any{"text/html": map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+
+		responses[strconv.Itoa(status)] = rs
+	}
+
+	return responses
+}
+
+// Describes the JSON errors sent by `apiError` and `apiValidationError`.
+func errorSchema(status int) map[string]any {
+	if status == http.StatusUnprocessableEntity {
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"errors": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{"type": "string"},
+		},
+	}
+}
+
+// Describes the type as a JSON schema, naming the fields of structs after their `tag` tags, as the
+// decoders do.
+func schemaOf(t reflect.Type, tag string) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem(), tag)
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), tag)}
+	case reflect.Struct:
+		props := map[string]any{}
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+
+			props[name] = schemaOf(f.Type, tag)
+		}
+
+		return map[string]any{"type": "object", "properties": props}
+	default:
+		return map[string]any{}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+
+	routes := app.router().routes
+
+	t.Run("Every route is described", func(t *testing.T) {
+		for _, rt := range routes {
+			if _, ok := operations[rt]; !ok {
+				t.Errorf("%s %s is missing from the OpenAPI document", rt.method, rt.path)
+			}
+		}
+	})
+
+	t.Run("Every description has a route", func(t *testing.T) {
+		registered := map[route]bool{}
+		for _, rt := range routes {
+			registered[rt] = true
+		}
+
+		for rt := range operations {
+			if !registered[rt] {
+				t.Errorf("%s %s is described but not registered", rt.method, rt.path)
+			}
+		}
+	})
+
+	t.Run("Document", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, headers, body := ts.get(t, "/api/openapi.json")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+		var doc struct {
+			OpenAPI string                               `json:"openapi"`
+			Paths   map[string]map[string]map[string]any `json:"paths"`
+		}
+
+		err := json.Unmarshal([]byte(body), &doc)
+		assert.NilError(t, err)
+
+		assert.Equal(t, doc.OpenAPI, "3.0.3")
+		assert.Equal(t, len(doc.Paths["/n/{slug}"]), 2)
+
+		// Form-encoded bodies are described by the structs they are decoded into.
+		assert.StringContains(t, body, `"application/x-www-form-urlencoded":{"schema":{"properties":{"content":{"type":"string"}`)
+	})
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		wantPath   string
+		wantParams int
+	}{
+		{
+			name:     "Fixed path",
+			pattern:  "/notes",
+			wantPath: "/notes",
+		},
+		{
+			name:       "Named parameter",
+			pattern:    "/n/:slug/history",
+			wantPath:   "/n/{slug}/history",
+			wantParams: 1,
+		},
+		{
+			name:       "Catch-all parameter",
+			pattern:    "/static/*filepath",
+			wantPath:   "/static/{filepath}",
+			wantParams: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, params := openAPIPath(tt.pattern)
+
+			assert.Equal(t, path, tt.wantPath)
+			assert.Equal(t, len(params), tt.wantParams)
+		})
+	}
+}
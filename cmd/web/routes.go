@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gustavodiasag/notebox/ui"
 
@@ -9,10 +10,43 @@ import (
 	"github.com/justinas/alice"
 )
 
+// Route registered in the router, identified by its method and path pattern.
+type route struct {
+	method string
+	path   string
+}
+
+// Router keeping track of the routes registered in it, so that they can be described in the
+// OpenAPI document.
+type routeRecorder struct {
+	*httprouter.Router
+	routes []route
+}
+
+func (rr *routeRecorder) Handler(method, path string, handler http.Handler) {
+	rr.routes = append(rr.routes, route{method, path})
+	rr.Router.Handler(method, path, handler)
+}
+
+func (rr *routeRecorder) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	rr.Handler(method, path, handler)
+}
+
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	// Middleware chain containing the standard middleware for the application.
+	std := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+
+	return std.Then(app.router())
+}
+
+func (app *application) router() *routeRecorder {
+	router := &routeRecorder{Router: httprouter.New()}
 	// Assigns a custom handler for 404 responses.
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiNotFound(w)
+			return
+		}
 		app.notFound(w)
 	})
 
@@ -26,11 +60,27 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/", dyn.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/about", dyn.ThenFunc(app.about))
-	router.Handler(http.MethodGet, "/note/view/:id", dyn.ThenFunc(app.noteView))
+	router.Handler(http.MethodGet, "/search", dyn.ThenFunc(app.search))
+	router.Handler(http.MethodGet, "/notes", dyn.ThenFunc(app.noteList))
+	router.Handler(http.MethodGet, "/tag/:name", dyn.ThenFunc(app.tagView))
+	router.Handler(http.MethodGet, "/n/:slug", dyn.ThenFunc(app.noteView))
+	router.Handler(http.MethodPost, "/n/:slug", dyn.ThenFunc(app.noteBurnPost))
+	router.Handler(http.MethodPost, "/n/:slug/unlock", dyn.ThenFunc(app.noteUnlockPost))
+	router.Handler(http.MethodGet, "/n/:slug/history", dyn.ThenFunc(app.noteHistory))
+	router.Handler(http.MethodGet, "/note/view/:id", dyn.ThenFunc(app.noteViewByID))
 	router.Handler(http.MethodGet, "/user/signup", dyn.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dyn.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dyn.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dyn.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/totp", dyn.ThenFunc(app.userLoginTOTP))
+	router.Handler(http.MethodPost, "/user/login/totp", dyn.ThenFunc(app.userLoginTOTPPost))
+	router.Handler(http.MethodGet, "/user/verify", dyn.ThenFunc(app.userVerify))
+	router.Handler(http.MethodPost, "/user/verify", dyn.ThenFunc(app.userVerifyPost))
+	router.Handler(http.MethodGet, "/user/verify/:token", dyn.ThenFunc(app.userVerifyToken))
+	router.Handler(http.MethodGet, "/user/password/forgot", dyn.ThenFunc(app.passwordForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", dyn.ThenFunc(app.passwordForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset/:token", dyn.ThenFunc(app.passwordReset))
+	router.Handler(http.MethodPost, "/user/password/reset/:token", dyn.ThenFunc(app.passwordResetPost))
 
 	// Authenticated-only routes.
 	protected := dyn.Append(app.requireAuthentication)
@@ -38,12 +88,43 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
 	router.Handler(http.MethodGet, "/note/create", protected.ThenFunc(app.noteCreate))
 	router.Handler(http.MethodPost, "/note/create", protected.ThenFunc(app.noteCreatePost))
+	router.Handler(http.MethodGet, "/note/create/encrypted", protected.ThenFunc(app.noteCreateEncrypted))
+	router.Handler(http.MethodPost, "/note/create/encrypted", protected.ThenFunc(app.noteCreateEncryptedPost))
+	router.Handler(http.MethodGet, "/note/edit/:id", protected.ThenFunc(app.noteEdit))
+	router.Handler(http.MethodPost, "/note/edit/:id", protected.ThenFunc(app.noteEditPost))
+	router.Handler(http.MethodGet, "/note/extend/:id", protected.ThenFunc(app.noteExtend))
+	router.Handler(http.MethodPost, "/note/extend/:id", protected.ThenFunc(app.noteExtendPost))
+	router.Handler(http.MethodPost, "/note/delete/:id", protected.ThenFunc(app.noteDeletePost))
+	router.Handler(http.MethodPost, "/note/restore/:id", protected.ThenFunc(app.noteRestorePost))
+	router.Handler(http.MethodGet, "/trash", protected.ThenFunc(app.trash))
+	router.Handler(http.MethodPost, "/trash/restore/:id", protected.ThenFunc(app.trashRestorePost))
+	router.Handler(http.MethodPost, "/trash/purge/:id", protected.ThenFunc(app.trashPurgePost))
+	router.Handler(http.MethodPost, "/account/tokens/create", protected.ThenFunc(app.tokenCreatePost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.tokenRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(app.sessionRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-others", protected.ThenFunc(app.sessionRevokeOthersPost))
+	router.Handler(http.MethodGet, "/account/totp/setup", protected.ThenFunc(app.totpSetup))
+	router.Handler(http.MethodPost, "/account/totp/setup", protected.ThenFunc(app.totpSetupPost))
+	router.Handler(http.MethodGet, "/account/totp/qrcode.png", protected.ThenFunc(app.totpSetupQRCode))
 	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.passwordUpdate))
 	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.passwordUpdatePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
 
-	// Middleware chain containing the standard middleware for the application.
-	std := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// Routes of the JSON API, which accept either the sessions of the web interface or API tokens.
+	api := alice.New(app.sessionManager.LoadAndSave, apiNoSurf, app.authenticate, app.authenticateToken)
+	apiProtected := api.Append(app.requireAPIAuthentication)
+	apiWrite := apiProtected.Append(app.requireWriteScope)
+
+	router.Handler(http.MethodGet, "/api/v1/notes", api.ThenFunc(app.apiNoteList))
+	router.Handler(http.MethodGet, "/api/v1/notes/:slug", api.ThenFunc(app.apiNoteView))
+	router.Handler(http.MethodGet, "/api/v1/search", api.ThenFunc(app.apiNoteSearch))
+	router.Handler(http.MethodPost, "/api/v1/notes", apiWrite.ThenFunc(app.apiNoteCreate))
+	router.Handler(http.MethodPatch, "/api/v1/notes/:slug", apiWrite.ThenFunc(app.apiNoteUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/notes/:slug", apiWrite.ThenFunc(app.apiNoteDelete))
+	router.Handler(http.MethodGet, "/api/v1/account", apiProtected.ThenFunc(app.apiAccountView))
+
+	// Describes every route above, including itself.
+	router.HandlerFunc(http.MethodGet, "/api/openapi.json", app.openAPI(router))
 
-	return std.Then(router)
+	return router
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/models"
+)
+
+// Interval after which the moment a session was last seen is updated, so that it isn't stored
+// again on every request.
+const sessionTouchInterval = time.Minute
+
+// Maximum length of the user agents kept in sessions, which are otherwise unbounded.
+const maxUserAgentLength = 200
+
+// Session in which a user is logged in, as shown to them so that they can recognize their devices.
+type sessionInfo struct {
+	// Identifies the session without disclosing its token, which would allow it to be hijacked.
+	ID        string
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+	// Whether it's the session of the request.
+	Current bool
+}
+
+// Hash the session of the token is indexed by, which identifies it without disclosing the token.
+func sessionID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Records the session of the request in the index of the sessions of the user, along with the
+// device they logged in from, replacing the entry of `oldToken`, which the session had before its
+// token was renewed. `pending` is set while the user still has to enter the code of their
+// authenticator app.
+func (app *application) indexSession(r *http.Request, oldToken string, userID int, pending bool) error {
+	if oldToken != "" {
+		err := app.sessions.Delete(oldToken)
+		if err != nil {
+			return err
+		}
+	}
+
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLength], "")
+	}
+
+	return app.sessions.Insert(app.sessionManager.Token(r.Context()), userID, pending, clientIP(r), ua)
+}
+
+// Reports whether the session of the request is still indexed as one of the user's, which stops
+// being the case once it's revoked, updating the moment it was last seen along the way.
+func (app *application) checkSession(r *http.Request, userID int) (bool, error) {
+	token := app.sessionManager.Token(r.Context())
+
+	s, err := app.sessions.Get(token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if s.UserID != userID {
+		return false, nil
+	}
+
+	if time.Since(s.LastSeen) >= sessionTouchInterval {
+		err = app.sessions.Touch(token, clientIP(r))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Returns the sessions in which the user is logged in, the most recently seen first.
+func (app *application) userSessions(r *http.Request, userID int) ([]sessionInfo, error) {
+	current := sessionID(app.sessionManager.Token(r.Context()))
+
+	indexed, err := app.sessions.ForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]sessionInfo, 0, len(indexed))
+
+	for _, s := range indexed {
+		sessions = append(sessions, sessionInfo{
+			ID:        s.ID,
+			Created:   s.Created,
+			LastSeen:  s.LastSeen,
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			Current:   s.ID == current,
+		})
+	}
+
+	return sessions, nil
+}
+
+// Revokes another session of the user, reporting whether there was such a session.
+func (app *application) destroySession(r *http.Request, userID int, id string) (bool, error) {
+	if id == sessionID(app.sessionManager.Token(r.Context())) {
+		return false, nil
+	}
+
+	err := app.sessions.Revoke(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Revokes every session in which the user is logged in, or waiting to enter the code of their
+// authenticator app, including the one of the request unless `keepCurrent` is set.
+func (app *application) destroyUserSessions(r *http.Request, id int, keepCurrent bool) error {
+	current := app.sessionManager.Token(r.Context())
+
+	except := ""
+	if keepCurrent {
+		except = current
+	}
+
+	err := app.sessions.RevokeAll(id, except)
+	if err != nil {
+		return err
+	}
+
+	if !keepCurrent {
+		if app.sessionManager.GetInt(r.Context(), "authenticatedUserID") == id {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+		}
+		if app.sessionManager.GetInt(r.Context(), "totpUserID") == id {
+			app.clearTOTPLogin(r)
+		}
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	if !keepCurrent {
+		return nil
+	}
+
+	return app.sessions.Renew(current, app.sessionManager.Token(r.Context()))
+}
@@ -4,22 +4,41 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gustavodiasag/notebox/internal/diff"
+	"github.com/gustavodiasag/notebox/internal/markup"
 	"github.com/gustavodiasag/notebox/internal/models"
 	"github.com/gustavodiasag/notebox/ui"
 )
 
 // Acts as the holding structure for any dynamic data passed to HTML templates.
 type templateData struct {
-	CurrentYear     int
-	User            *models.User
-	Note            *models.Note
-	Notes           []*models.Note
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	User                *models.User
+	Note                *models.Note
+	Notes               []*models.Note
+	Revisions           []*models.Revision
+	Diff                []diff.Line
+	Tag                 string
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	PrevURL             string
+	NextURL             string
+	TrashRetentionDays  int
+	Tokens              []*models.Token
+	NewToken            string
+	TOTPEnabled         bool
+	RecoveryCodes       []string
+	// Data URL from which the recovery codes can be downloaded as a text file.
+	RecoveryCodesURL template.URL
+	Sessions         []sessionInfo
 }
 
 func fmtDate(t time.Time) string {
@@ -29,8 +48,74 @@ func fmtDate(t time.Time) string {
 	return t.UTC().Format("02 Jan, 2006")
 }
 
+func fmtDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("02 Jan, 2006 at 15:04 UTC")
+}
+
+// Maximum number of characters displayed in a search result snippet.
+const snippetLength = 200
+
+// Extracts an excerpt of the text around the first occurrence of any of the words in the query,
+// wrapping every occurrence within the excerpt in a `<mark>` element. The remaining text is
+// escaped, so the result is safe to be rendered as HTML.
+func highlight(text, query string) template.HTML {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, regexp.QuoteMeta(term))
+	}
+
+	var rx *regexp.Regexp
+	if len(terms) > 0 {
+		rx = regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+	}
+
+	runes := []rune(text)
+	start := 0
+
+	// Leaves some context before the first match.
+	if rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			start = max(utf8.RuneCountInString(text[:loc[0]])-snippetLength/4, 0)
+		}
+	}
+	end := min(start+snippetLength, len(runes))
+	excerpt := string(runes[start:end])
+
+	var b strings.Builder
+
+	if start > 0 {
+		b.WriteString("&hellip;")
+	}
+
+	last := 0
+	if rx != nil {
+		for _, loc := range rx.FindAllStringIndex(excerpt, -1) {
+			b.WriteString(template.HTMLEscapeString(excerpt[last:loc[0]]))
+			b.WriteString("<mark>")
+			b.WriteString(template.HTMLEscapeString(excerpt[loc[0]:loc[1]]))
+			b.WriteString("</mark>")
+			last = loc[1]
+		}
+	}
+	b.WriteString(template.HTMLEscapeString(excerpt[last:]))
+
+	if end < len(runes) {
+		b.WriteString("&hellip;")
+	}
+
+	return template.HTML(b.String())
+}
+
 var functions = template.FuncMap{
-	"fmtDate": fmtDate,
+	"fmtDate":       fmtDate,
+	"fmtDateTime":   fmtDateTime,
+	"highlight":     highlight,
+	"markdown":      markup.Markdown,
+	"highlightCode": markup.Highlight,
+	"languages":     func() []string { return markup.Languages },
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
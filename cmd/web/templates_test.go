@@ -1,6 +1,8 @@
 package main
 
 import (
+	"html/template"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,3 +40,81 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestFmtDateTime(t *testing.T) {
+	tests := []struct {
+		name string
+		tm   time.Time
+		want string
+	}{
+		{
+			name: "UTC",
+			tm:   time.Date(2022, 3, 17, 10, 15, 0, 0, time.UTC),
+			want: "17 Mar, 2022 at 10:15 UTC",
+		},
+		{
+			name: "Empty",
+			tm:   time.Time{},
+			want: "",
+		},
+		{
+			name: "CET",
+			tm:   time.Date(2022, 3, 17, 10, 15, 0, 0, time.FixedZone("CET", 1*60*60)),
+			want: "17 Mar, 2022 at 09:15 UTC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmtDateTime(tt.tm), tt.want)
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a ", 100)
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  template.HTML
+	}{
+		{
+			name:  "Single match",
+			text:  "An old silent pond",
+			query: "pond",
+			want:  "An old silent <mark>pond</mark>",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "An old silent pond",
+			query: "OLD Pond",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Escaped",
+			text:  "<script>pond</script>",
+			query: "pond",
+			want:  "&lt;script&gt;<mark>pond</mark>&lt;/script&gt;",
+		},
+		{
+			name:  "No match",
+			text:  "An old silent pond",
+			query: "frog",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Truncated",
+			text:  long + "pond" + long,
+			query: "pond",
+			want:  template.HTML("&hellip;" + long[:50] + "<mark>pond</mark>" + long[:146] + "&hellip;"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, highlight(tt.text, tt.query), tt.want)
+		})
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"github.com/gustavodiasag/notebox/internal/mailer"
 	"github.com/gustavodiasag/notebox/internal/models/mocks"
 )
 
@@ -46,9 +48,19 @@ func newTestApplication(t *testing.T) *application {
 		infoLog:        log.New(io.Discard, "", 0),
 		notes:          &mocks.NoteModel{},
 		users:          &mocks.UserModel{},
+		tokens:         &mocks.TokenModel{},
+		totp:           &mocks.TOTPModel{},
+		lockouts:       &mocks.LockoutModel{},
+		sessions:       &mocks.SessionModel{},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newLimiter(5, 15*time.Minute),
+		ipBackoff:      newBackoff(20, 30*time.Second, 15*time.Minute, time.Hour),
+		accountBackoff: newBackoff(5, 30*time.Second, 15*time.Minute, time.Hour),
+		trashRetention: 30 * 24 * time.Hour,
+		mailer:         mailer.NewLog(io.Discard, "no-reply@example.com"),
+		baseURL:        "https://localhost:4000",
 	}
 }
 
@@ -108,3 +120,64 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Sends the JSON body, passing the CSRF token through the header checked by nosurf, since it can't
+// be part of the body.
+func (ts *testServer) postJSON(t *testing.T, urlPath, body, csrfToken string) (int, http.Header, string) {
+	return ts.sendJSON(t, http.MethodPost, urlPath, body, csrfToken)
+}
+
+func (ts *testServer) sendJSON(t *testing.T, method, urlPath, body, csrfToken string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfToken)
+
+	return ts.do(t, req)
+}
+
+// Sends the JSON body as an API client authenticated through the given token would.
+func (ts *testServer) sendWithToken(t *testing.T, method, urlPath, body, token string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return ts.do(t, req)
+}
+
+func (ts *testServer) do(t *testing.T, req *http.Request) (int, http.Header, string) {
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	rsBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(rsBody))
+}
+
+// Logs in as the user provided by the `UserModel` mock, so that any subsequent request made through
+// the test server client is authenticated.
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pass")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
@@ -0,0 +1,150 @@
+package diff
+
+import "strings"
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Computes a line-based diff turning `a` into `b`, derived from the longest common subsequence
+// of their lines. The subsequence is found with Hirschberg's algorithm, which only keeps two rows
+// of the dynamic programming table in memory, so that comparing large notes doesn't require space
+// proportional to the product of their line counts.
+func Lines(a, b string) []Line {
+	x := split(a)
+	y := split(b)
+
+	lines := make([]Line, 0, len(x)+len(y))
+
+	// Lines shared at the start and end of both sides are left out of the costlier comparison.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		lines = append(lines, Line{Equal, x[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	lines = hirschberg(lines, x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])
+
+	for _, text := range x[len(x)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+
+	return lines
+}
+
+// Appends the diff turning `x` into `y` to `lines`, splitting `x` in half and `y` where the
+// longest common subsequences of both halves add up to the longest one of the whole.
+func hirschberg(lines []Line, x, y []string) []Line {
+	switch {
+	case len(x) == 0:
+		for _, text := range y {
+			lines = append(lines, Line{Insert, text})
+		}
+		return lines
+	case len(y) == 0:
+		for _, text := range x {
+			lines = append(lines, Line{Delete, text})
+		}
+		return lines
+	case len(x) == 1:
+		for j, text := range y {
+			if text == x[0] {
+				for _, text := range y[:j] {
+					lines = append(lines, Line{Insert, text})
+				}
+				lines = append(lines, Line{Equal, text})
+				for _, text := range y[j+1:] {
+					lines = append(lines, Line{Insert, text})
+				}
+				return lines
+			}
+		}
+		lines = append(lines, Line{Delete, x[0]})
+		for _, text := range y {
+			lines = append(lines, Line{Insert, text})
+		}
+		return lines
+	}
+
+	mid := len(x) / 2
+
+	forward := lcsRow(x[:mid], y, false)
+	backward := lcsRow(x[mid:], y, true)
+
+	split := 0
+	for j := range forward {
+		if forward[j]+backward[j] > forward[split]+backward[split] {
+			split = j
+		}
+	}
+
+	lines = hirschberg(lines, x[:mid], y[:split])
+
+	return hirschberg(lines, x[mid:], y[split:])
+}
+
+// Returns the lengths of the longest common subsequences of `x` and every prefix of `y`, the
+// element j corresponding to y[:j]. If `reverse` is set, the suffixes of both are compared
+// instead, the element j corresponding to y[j:].
+func lcsRow(x, y []string, reverse bool) []int {
+	prev := make([]int, len(y)+1)
+	curr := make([]int, len(y)+1)
+
+	for i := range x {
+		if reverse {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[len(x)-1-i] == y[j] {
+					curr[j] = prev[j+1] + 1
+				} else {
+					curr[j] = max(prev[j], curr[j+1])
+				}
+			}
+		} else {
+			for j := 1; j <= len(y); j++ {
+				if x[i] == y[j-1] {
+					curr[j] = prev[j-1] + 1
+				} else {
+					curr[j] = max(prev[j], curr[j-1])
+				}
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	// Content submitted through HTML forms uses CRLF line endings.
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
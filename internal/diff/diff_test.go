@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Line
+	}{
+		{
+			name: "Identical",
+			a:    "foo\nbar",
+			b:    "foo\nbar",
+			want: []Line{{Equal, "foo"}, {Equal, "bar"}},
+		},
+		{
+			name: "Insertion",
+			a:    "foo\nbaz",
+			b:    "foo\nbar\nbaz",
+			want: []Line{{Equal, "foo"}, {Insert, "bar"}, {Equal, "baz"}},
+		},
+		{
+			name: "Deletion",
+			a:    "foo\nbar\nbaz",
+			b:    "foo\nbaz",
+			want: []Line{{Equal, "foo"}, {Delete, "bar"}, {Equal, "baz"}},
+		},
+		{
+			name: "Replacement",
+			a:    "foo\nbar",
+			b:    "foo\nqux",
+			want: []Line{{Equal, "foo"}, {Delete, "bar"}, {Insert, "qux"}},
+		},
+		{
+			name: "CRLF",
+			a:    "foo\r\nbar\r\n",
+			b:    "foo\nbar",
+			want: []Line{{Equal, "foo"}, {Equal, "bar"}},
+		},
+		{
+			name: "Empty",
+			a:    "",
+			b:    "foo",
+			want: []Line{{Insert, "foo"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Lines(tt.a, tt.b)
+
+			assert.Equal(t, len(lines), len(tt.want))
+
+			for i := 0; i < min(len(lines), len(tt.want)); i++ {
+				assert.Equal(t, lines[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	words := []string{"foo", "bar", "baz", "qux"}
+
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+
+	for n := 0; n < 500; n++ {
+		x, y := random(), random()
+
+		var a, b []string
+		equal := 0
+
+		for _, line := range Lines(strings.Join(x, "\n"), strings.Join(y, "\n")) {
+			switch line.Op {
+			case Equal:
+				a = append(a, line.Text)
+				b = append(b, line.Text)
+				equal++
+			case Delete:
+				a = append(a, line.Text)
+			case Insert:
+				b = append(b, line.Text)
+			}
+		}
+
+		// The diff must turn one side into the other, keeping as many lines as possible.
+		assert.Equal(t, strings.Join(a, "\n"), strings.Join(x, "\n"))
+		assert.Equal(t, strings.Join(b, "\n"), strings.Join(y, "\n"))
+		assert.Equal(t, equal, lcs(x, y))
+	}
+}
+
+// Computes the length of the longest common subsequence with the full dynamic programming table.
+func lcs(x, y []string) int {
+	table := make([][]int, len(x)+1)
+	for i := range table {
+		table[i] = make([]int, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	return table[0][0]
+}
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Sends emails rendered from the templates in the `templates` directory, each of them defining a
+// "subject" and a "plainBody" template.
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// Renders the subject and body of an email from the template file.
+func render(templateFile string, data any) (subject, body string, err error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+
+	err = tmpl.ExecuteTemplate(&buf, "subject", data)
+	if err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+
+	err = tmpl.ExecuteTemplate(&buf, "plainBody", data)
+	if err != nil {
+		return "", "", err
+	}
+	body = strings.TrimSpace(buf.String()) + "\n"
+
+	return subject, body, nil
+}
+
+// Builds the message sent to the recipient, headers included.
+func message(sender, recipient, subject, body string) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", sender)
+	fmt.Fprintf(&buf, "To: %s\r\n", recipient)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
+
+// Sends emails through an SMTP server.
+type SMTP struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+	from   string
+}
+
+// Returns a mailer sending emails through the SMTP server at `host`, authenticating only if a
+// username is given. `sender` is the address emails are sent from, such as
+// "Notebox <no-reply@example.com>".
+func NewSMTP(host string, port int, username, password, sender string) (*SMTP, error) {
+	from, err := mail.ParseAddress(sender)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+
+	m := &SMTP{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		sender: sender,
+		from:   from.Address,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m, nil
+}
+
+func (m *SMTP) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{recipient}, message(m.sender, recipient, subject, body))
+}
+
+// Writes emails to `w` instead of sending them, which is meant for development, where the
+// application's log or a file can be used as a mailbox.
+type Log struct {
+	mu     sync.Mutex
+	w      io.Writer
+	sender string
+}
+
+func NewLog(w io.Writer, sender string) *Log {
+	return &Log{w: w, sender: sender}
+}
+
+func (m *Log) Send(recipient, templateFile string, data any) error {
+	subject, body, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	// Emails sent concurrently would otherwise be interleaved.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = fmt.Fprintf(m.w, "%s\n", message(m.sender, recipient, subject, body))
+	return err
+}
@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestLogSend(t *testing.T) {
+	var buf bytes.Buffer
+
+	m := NewLog(&buf, "Notebox <no-reply@example.com>")
+
+	err := m.Send("alice@example.com", "user_verification.tmpl", map[string]any{
+		"Name": "Alice",
+		"URL":  "https://localhost:4000/user/verify/token",
+	})
+	assert.NilError(t, err)
+
+	email := buf.String()
+
+	assert.StringContains(t, email, "From: Notebox <no-reply@example.com>\r\n")
+	assert.StringContains(t, email, "To: alice@example.com\r\n")
+	assert.StringContains(t, email, "Subject: Verify your Notebox account\r\n")
+	assert.StringContains(t, email, "Hi Alice,")
+	assert.StringContains(t, email, "https://localhost:4000/user/verify/token")
+}
+
+func TestLogSendMissingTemplate(t *testing.T) {
+	m := NewLog(&bytes.Buffer{}, "no-reply@example.com")
+
+	err := m.Send("alice@example.com", "missing.tmpl", nil)
+	if err == nil {
+		t.Error("got: nil; expected an error")
+	}
+}
+
+func TestNewSMTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		sender   string
+		wantFrom string
+		wantErr  bool
+	}{
+		{
+			name:     "Named sender",
+			sender:   "Notebox <no-reply@example.com>",
+			wantFrom: "no-reply@example.com",
+		},
+		{
+			name:     "Bare address",
+			sender:   "no-reply@example.com",
+			wantFrom: "no-reply@example.com",
+		},
+		{
+			name:    "Invalid sender",
+			sender:  "Notebox",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewSMTP("localhost", 25, "", "", tt.sender)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got: nil; expected an error")
+				}
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, m.from, tt.wantFrom)
+			assert.Equal(t, m.addr, "localhost:25")
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		language string
+		wantHTML string
+	}{
+		{
+			name:     "Go",
+			src:      "package main",
+			language: "go",
+			wantHTML: `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Unknown language",
+			src:      "package main",
+			language: "foo",
+			wantHTML: "package main",
+		},
+		{
+			name:     "Escaped",
+			src:      "<script>alert(1)</script>",
+			language: "text",
+			wantHTML: "&lt;script&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Highlight(tt.src, tt.language)
+
+			assert.NilError(t, err)
+			assert.StringContains(t, string(html), `<pre class="chroma">`)
+			assert.StringContains(t, string(html), tt.wantHTML)
+
+			if strings.Contains(string(html), "style=") {
+				t.Errorf("got: %q; expected no inline styles", html)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Shebang",
+			src:  "#!/bin/bash\necho foo",
+			want: "bash",
+		},
+		{
+			name: "Prose",
+			src:  "An old silent pond",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.src), tt.want)
+		})
+	}
+}
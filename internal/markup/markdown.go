@@ -0,0 +1,41 @@
+package markup
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// CommonMark parser extended with GitHub Flavored Markdown, allowing tables, strikethroughs and
+// autolinks. Raw HTML present in the source is omitted from the output.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+// Allowlist applied to every document rendered from user input. No `style` attributes or scripts
+// get through, so the output complies with the application's Content Security Policy.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Keeps the language hint of fenced code blocks.
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	return p
+}
+
+// Converts CommonMark source into sanitized HTML.
+func Markdown(src string) (template.HTML, error) {
+	var buf bytes.Buffer
+
+	err := md.Convert([]byte(src), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
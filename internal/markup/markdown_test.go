@@ -0,0 +1,69 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantHTML   string
+		unwantHTML string
+	}{
+		{
+			name:     "Heading",
+			src:      "# Title",
+			wantHTML: "<h1>Title</h1>",
+		},
+		{
+			name:     "List",
+			src:      "- foo\n- bar",
+			wantHTML: "<li>foo</li>",
+		},
+		{
+			name:     "Table",
+			src:      "| a | b |\n|---|---|\n| 1 | 2 |",
+			wantHTML: "<td>1</td>",
+		},
+		{
+			name:     "Fenced code",
+			src:      "```go\nfmt.Println()\n```",
+			wantHTML: `<code class="language-go">`,
+		},
+		{
+			name:       "Raw HTML",
+			src:        "<script>alert(1)</script>",
+			unwantHTML: "<script>",
+		},
+		{
+			name:       "Javascript link",
+			src:        "[click](javascript:alert(1))",
+			unwantHTML: "javascript:",
+		},
+		{
+			name:       "Inline style",
+			src:        "<p style='color: red'>foo</p>",
+			unwantHTML: "style",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Markdown(tt.src)
+
+			assert.NilError(t, err)
+
+			if tt.wantHTML != "" {
+				assert.StringContains(t, string(html), tt.wantHTML)
+			}
+
+			if tt.unwantHTML != "" && strings.Contains(string(html), tt.unwantHTML) {
+				t.Errorf("got: %q; expected not to contain: %q", html, tt.unwantHTML)
+			}
+		})
+	}
+}
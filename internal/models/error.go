@@ -8,4 +8,8 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	// Returned when the credentials are correct, but the email address wasn't verified yet.
+	ErrUnverifiedAccount = errors.New("models: unverified account")
+	// Returned when two-factor authentication is used without a key encrypting the secrets.
+	ErrNoTOTPKey = errors.New("models: no TOTP encryption key")
 )
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of lockouts, depending on what was blocked after too many failed logins.
+const (
+	LockoutIP      = "ip"
+	LockoutAccount = "account"
+)
+
+// Audit record of a lockout, kept after it ends.
+type Lockout struct {
+	ID   int
+	Kind string
+	// What was locked out, either an IP address or an email address, depending on the kind.
+	Subject string
+	// Address the last failed attempt came from.
+	IP      string
+	Until   time.Time
+	Created time.Time
+}
+
+type LockoutModelInterface interface {
+	Insert(kind, subject, ip string, until time.Time) error
+}
+
+type LockoutModel struct {
+	DB *sql.DB
+}
+
+func (m *LockoutModel) Insert(kind, subject, ip string, until time.Time) error {
+	stmt := `
+		INSERT INTO lockout (kind, subject, ip, locked_until, created)
+		VALUES (?, ?, ?, ?, UTC_TIMESTAMP())
+	`
+	_, err := m.DB.Exec(stmt, kind, subject, ip, until.UTC())
+	return err
+}
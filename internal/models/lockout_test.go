@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestLockoutModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := LockoutModel{db}
+
+	until := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+
+	err := m.Insert(LockoutAccount, "alice@example.com", "192.0.2.1", until)
+	assert.NilError(t, err)
+
+	err = m.Insert(LockoutIP, "192.0.2.1", "192.0.2.1", until.Add(time.Minute))
+	assert.NilError(t, err)
+
+	var kind, subject string
+	var lockedUntil time.Time
+
+	stmt := `SELECT kind, subject, locked_until FROM lockout ORDER BY id LIMIT 1`
+
+	err = db.QueryRow(stmt).Scan(&kind, &subject, &lockedUntil)
+	assert.NilError(t, err)
+
+	assert.Equal(t, kind, LockoutAccount)
+	assert.Equal(t, subject, "alice@example.com")
+	assert.Equal(t, lockedUntil.Equal(until), true)
+
+	var count int
+
+	err = db.QueryRow(`SELECT COUNT(*) FROM lockout`).Scan(&count)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+}
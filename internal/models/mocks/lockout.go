@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/models"
+)
+
+// Keeps the lockouts in memory, so that tests can check they were recorded.
+type LockoutModel struct {
+	mu       sync.Mutex
+	lockouts []*models.Lockout
+}
+
+func (m *LockoutModel) Insert(kind, subject, ip string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lockouts = append(m.lockouts, &models.Lockout{
+		ID:      len(m.lockouts) + 1,
+		Kind:    kind,
+		Subject: subject,
+		IP:      ip,
+		Until:   until,
+		Created: time.Now(),
+	})
+
+	return nil
+}
+
+// Returns the recorded lockouts, in the order they were recorded.
+func (m *LockoutModel) Recorded() []*models.Lockout {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*models.Lockout{}, m.lockouts...)
+}
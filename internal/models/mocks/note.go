@@ -1,34 +1,350 @@
 package mocks
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gustavodiasag/notebox/internal/models"
 )
 
 var mockNote = &models.Note{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Slug:       "note000001",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"haiku", "nature"},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// Note owned by a user other than the one authenticated in the handler tests.
+var mockForeignNote = &models.Note{
+	ID:         3,
+	Slug:       "note000003",
+	UserID:     2,
+	Author:     "Bob",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockMarkdownNote = &models.Note{
+	ID:         4,
+	Slug:       "note000004",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Haiku",
+	Content:    "# Haiku\n\n- An old silent pond\n- A frog jumps into the pond",
+	Format:     models.FormatMarkdown,
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockCodeNote = &models.Note{
+	ID:         5,
+	Slug:       "note000005",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Hello, world",
+	Content:    "package main",
+	Format:     models.FormatPlain,
+	Language:   "go",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// Private note owned by a user other than the one authenticated in the handler tests.
+var mockPrivateNote = &models.Note{
+	ID:         6,
+	Slug:       "note000006",
+	UserID:     2,
+	Author:     "Bob",
+	Title:      "Winter solitude",
+	Content:    "Winter solitude",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// Burn-after-reading note owned by a user other than the one authenticated in the handler tests.
+var mockBurnNote = &models.Note{
+	ID:         7,
+	Slug:       "note000007",
+	UserID:     2,
+	Author:     "Bob",
+	Title:      "Recovery codes",
+	Content:    "1234-5678",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityUnlisted,
+	Burn:       true,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// Password-protected note owned by a user other than the one authenticated in the handler tests.
+var mockProtectedNote = &models.Note{
+	ID:         8,
+	Slug:       "note000008",
+	UserID:     2,
+	Author:     "Bob",
+	Title:      "Wi-Fi",
+	Content:    "correct horse battery staple",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Protected:  true,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockEncryptedNote = &models.Note{
+	ID:         9,
+	Slug:       "note000009",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Secret haiku",
+	Content:    "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGw==",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityUnlisted,
+	Encrypted:  true,
+	Created:    time.Now(),
+	// Never expires.
+}
+
+// Note in the trash of the user authenticated in the handler tests.
+var mockDeletedNote = &models.Note{
+	ID:         10,
+	Slug:       "note000010",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "A giant firefly",
+	Content:    "A giant firefly",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Deleted:    time.Now(),
+}
+
+// Note returned once any note is inserted, since the mocks don't keep the notes they're given.
+var mockInsertedNote = &models.Note{
+	ID:         11,
+	Slug:       "note000011",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"haiku", "nature"},
+	Created:    time.Now(),
+	Expires:    time.Now(),
 }
 
 type NoteModel struct{}
 
-func (m *NoteModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *NoteModel) Insert(note *models.Note, password string) (int, error) {
+	note.Slug = mockInsertedNote.Slug
+	return mockInsertedNote.ID, nil
 }
 
 func (m *NoteModel) Get(id int) (*models.Note, error) {
+	var n models.Note
+
 	switch id {
 	case 1:
-		return mockNote, nil
+		n = *mockNote
+	case 3:
+		n = *mockForeignNote
+	case 4:
+		n = *mockMarkdownNote
+	case 5:
+		n = *mockCodeNote
+	case 6:
+		n = *mockPrivateNote
+	case 7:
+		n = *mockBurnNote
+	case 8:
+		n = *mockProtectedNote
+	case 9:
+		n = *mockEncryptedNote
+	case 11:
+		n = *mockInsertedNote
 	default:
 		return nil, models.ErrNoRecord
 	}
+	// Handlers are free to modify the note they get without affecting the other tests.
+	return &n, nil
+}
+
+func (m *NoteModel) GetBySlug(slug string) (*models.Note, error) {
+	for _, n := range []*models.Note{mockNote, mockForeignNote, mockMarkdownNote, mockCodeNote, mockPrivateNote, mockBurnNote, mockProtectedNote, mockEncryptedNote, mockInsertedNote} {
+		if n.Slug == slug {
+			return m.Get(n.ID)
+		}
+	}
+
+	return nil, models.ErrNoRecord
 }
 
 func (m *NoteModel) Latest() ([]*models.Note, error) {
 	return []*models.Note{mockNote}, nil
 }
+
+func (m *NoteModel) Update(note *models.Note) error {
+	switch note.ID {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *NoteModel) Trash(userID int) ([]*models.Note, error) {
+	if userID == mockDeletedNote.UserID {
+		return []*models.Note{mockDeletedNote}, nil
+	}
+
+	return []*models.Note{}, nil
+}
+
+func (m *NoteModel) Restore(id, userID int) error {
+	if id == mockDeletedNote.ID && userID == mockDeletedNote.UserID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *NoteModel) Purge(id, userID int) error {
+	if id == mockDeletedNote.ID && userID == mockDeletedNote.UserID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *NoteModel) PurgeDeleted(retention time.Duration, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *NoteModel) Extend(id int, expires time.Time) error {
+	switch id {
+	case 1, 3, 9:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *NoteModel) Delete(id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *NoteModel) Burn(id int) error {
+	switch id {
+	case 7:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *NoteModel) PurgeExpired(grace time.Duration, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *NoteModel) Unlock(id int, password string) error {
+	switch {
+	case id != 8:
+		return models.ErrNoRecord
+	case password != "pass":
+		return models.ErrInvalidCredentials
+	default:
+		return nil
+	}
+}
+
+func (m *NoteModel) Revisions(id int) ([]*models.Revision, error) {
+	switch id {
+	case 1:
+		return []*models.Revision{
+			{
+				ID:      1,
+				NoteID:  1,
+				Number:  1,
+				UserID:  1,
+				Author:  "Alice",
+				Title:   "An old silent pond",
+				Content: "An old pond",
+				Format:  models.FormatPlain,
+				Created: time.Now(),
+			},
+			{
+				ID:      2,
+				NoteID:  1,
+				Number:  2,
+				UserID:  1,
+				Author:  "Alice",
+				Title:   mockNote.Title,
+				Content: mockNote.Content,
+				Format:  mockNote.Format,
+				Created: time.Now(),
+			},
+		}, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *NoteModel) Search(query string, page int) ([]*models.Note, error) {
+	notes := []*models.Note{}
+
+	if page != 1 {
+		return notes, nil
+	}
+
+	for _, n := range []*models.Note{mockNote, mockForeignNote, mockMarkdownNote, mockCodeNote, mockPrivateNote, mockBurnNote, mockProtectedNote, mockEncryptedNote} {
+		if n.Visibility != models.VisibilityPublic || n.Burn || n.Encrypted || n.Protected {
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(n.Title+" "+n.Content), strings.ToLower(query)) {
+			notes = append(notes, n)
+		}
+	}
+
+	return notes, nil
+}
+
+func (m *NoteModel) List(before, after, limit int) ([]*models.Note, error) {
+	if (before == 0 || mockNote.ID < before) && mockNote.ID > after && limit > 0 {
+		return []*models.Note{mockNote}, nil
+	}
+
+	return []*models.Note{}, nil
+}
+
+func (m *NoteModel) ByTag(tag string) ([]*models.Note, error) {
+	for _, t := range mockNote.Tags {
+		if t == tag {
+			return []*models.Note{mockNote}, nil
+		}
+	}
+
+	return []*models.Note{}, nil
+}
@@ -0,0 +1,142 @@
+package mocks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/models"
+)
+
+// Unlike the other mocks, the sessions are kept in memory, given that the handler tests log in from
+// several clients and revoke their sessions from one another.
+type SessionModel struct {
+	mu       sync.Mutex
+	sessions map[string]*models.Session
+}
+
+func hashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (m *SessionModel) Insert(token string, userID int, pending bool, ip, userAgent string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions == nil {
+		m.sessions = map[string]*models.Session{}
+	}
+
+	id := hashSessionToken(token)
+
+	m.sessions[id] = &models.Session{
+		ID:        id,
+		UserID:    userID,
+		Pending:   pending,
+		Created:   time.Now(),
+		LastSeen:  time.Now(),
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+
+	return nil
+}
+
+func (m *SessionModel) Get(token string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[hashSessionToken(token)]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	session := *s
+	return &session, nil
+}
+
+func (m *SessionModel) Touch(token, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[hashSessionToken(token)]; ok {
+		s.LastSeen = time.Now()
+		s.IP = ip
+	}
+
+	return nil
+}
+
+func (m *SessionModel) Renew(oldToken, newToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[hashSessionToken(oldToken)]; ok {
+		delete(m.sessions, s.ID)
+		s.ID = hashSessionToken(newToken)
+		m.sessions[s.ID] = s
+	}
+
+	return nil
+}
+
+func (m *SessionModel) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, hashSessionToken(token))
+
+	return nil
+}
+
+func (m *SessionModel) ForUser(userID int) ([]*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := []*models.Session{}
+
+	for _, s := range m.sessions {
+		if s.UserID == userID && !s.Pending {
+			session := *s
+			sessions = append(sessions, &session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions, nil
+}
+
+func (m *SessionModel) Revoke(id string, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || s.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.sessions, id)
+
+	return nil
+}
+
+func (m *SessionModel) RevokeAll(userID int, exceptToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	except := hashSessionToken(exceptToken)
+
+	for id, s := range m.sessions {
+		if s.UserID == userID && id != except {
+			delete(m.sessions, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/models"
+)
+
+var mockToken = &models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI",
+	Scope:   models.ScopeRead,
+	Created: time.Now(),
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	return "nb_newtoken", nil
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	if userID != 1 {
+		return []*models.Token{}, nil
+	}
+
+	return []*models.Token{mockToken}, nil
+}
+
+// Accepts a read-only and a read-write token, both belonging to the user provided by `UserModel`.
+func (m *TokenModel) Authenticate(plaintext string) (*models.Token, error) {
+	switch plaintext {
+	case "nb_readtoken":
+		return &models.Token{ID: 1, UserID: 1, Name: "CI", Scope: models.ScopeRead}, nil
+	case "nb_writetoken":
+		return &models.Token{ID: 2, UserID: 1, Name: "Deploy", Scope: models.ScopeWrite}, nil
+	default:
+		return nil, models.ErrInvalidCredentials
+	}
+}
+
+func (m *TokenModel) Revoke(id, userID int) error {
+	if id != 1 || userID != 1 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
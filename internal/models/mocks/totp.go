@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"github.com/gustavodiasag/notebox/internal/models"
+)
+
+// Dave, whose ID is 3, enabled two-factor authentication, while Alice is setting it up.
+type TOTPModel struct{}
+
+func (m *TOTPModel) Setup(userID int, secret string) error {
+	return nil
+}
+
+func (m *TOTPModel) Pending(userID int) (string, error) {
+	if userID != 1 {
+		return "", models.ErrNoRecord
+	}
+
+	return "JBSWY3DPEHPK3PXP", nil
+}
+
+func (m *TOTPModel) Enable(userID int, code string) ([]string, error) {
+	if userID != 1 {
+		return nil, models.ErrNoRecord
+	}
+	if code != "123456" {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	return []string{"aaaaa-bbbbb", "ccccc-ddddd"}, nil
+}
+
+func (m *TOTPModel) Enabled(userID int) (bool, error) {
+	return userID == 3, nil
+}
+
+func (m *TOTPModel) Validate(userID int, code string) error {
+	if userID != 3 || code != "123456" {
+		return models.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (m *TOTPModel) UseRecoveryCode(userID int, code string) error {
+	if userID != 3 || code != "aaaaa-bbbbb" {
+		return models.ErrInvalidCredentials
+	}
+
+	return nil
+}
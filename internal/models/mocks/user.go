@@ -8,40 +8,55 @@ import (
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, email, password string) (string, error) {
 	switch email {
 	case "foo@mail.com":
-		return models.ErrDuplicateEmail
+		return "", models.ErrDuplicateEmail
 	default:
-		return nil
+		return "verificationtoken", nil
 	}
 }
 
 func (m *UserModel) Get(id int) (*models.User, error) {
-	if id != 1 {
+	switch id {
+	case 1:
+		return &models.User{ID: 1, Name: "Alice", Email: "alice@example.com", Verified: true, Created: time.Now()}, nil
+	case 3:
+		return &models.User{ID: 3, Name: "Dave", Email: "dave@example.com", Verified: true, Created: time.Now()}, nil
+	default:
 		return nil, models.ErrNoRecord
 	}
+}
 
-	u := &models.User{
-		ID:      1,
-		Name:    "Alice",
-		Email:   "alice@example.com",
-		Created: time.Now(),
+// Knows about Alice, who is verified, and Carol, who isn't.
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return &models.User{ID: 1, Name: "Alice", Email: email, Verified: true, Created: time.Now()}, nil
+	case "carol@example.com":
+		return &models.User{ID: 2, Name: "Carol", Email: email, Created: time.Now()}, nil
+	default:
+		return nil, models.ErrNoRecord
 	}
-	return u, nil
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	if email == "alice@example.com" && password == "pass" {
 		return 1, nil
 	}
+	if email == "carol@example.com" && password == "pass" {
+		return 0, models.ErrUnverifiedAccount
+	}
+	if email == "dave@example.com" && password == "pass" {
+		return 3, nil
+	}
 
 	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 3:
 		return true, nil
 	default:
 		return false, nil
@@ -58,3 +73,35 @@ func (m *UserModel) UpdatePassword(id int, current, new string) error {
 
 	return nil
 }
+
+func (m *UserModel) Verify(token string) error {
+	if token != "validtoken" {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *UserModel) NewVerificationToken(id int) (string, error) {
+	if id != 2 {
+		return "", models.ErrNoRecord
+	}
+
+	return "newtoken", nil
+}
+
+func (m *UserModel) NewPasswordResetToken(id int) (string, error) {
+	if id != 1 && id != 2 {
+		return "", models.ErrNoRecord
+	}
+
+	return "resettoken", nil
+}
+
+func (m *UserModel) ResetPassword(token, password string) (int, error) {
+	if token != "resettoken" {
+		return 0, models.ErrNoRecord
+	}
+
+	return 1, nil
+}
@@ -3,57 +3,172 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Formats in which the content of a note can be interpreted.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
 )
 
+// Public notes are listed across the application, whereas unlisted ones are only reachable by
+// their URL. Private notes can only be seen by their authors.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// `Language` is used to highlight the content of plain notes, being blank when it isn't code.
+// Notes flagged with `Burn` are deleted the first time they are read, whereas `Protected` ones can
+// only be read with a password. The content of `Encrypted` notes is ciphertext which can only be
+// decrypted by the browser, given the key in their URL. Notes which never expire have a zero
+// `Expires`, whereas `Deleted` is only set for notes moved to the trash.
 type Note struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	Slug       string
+	UserID     int
+	Author     string
+	Title      string
+	Content    string
+	Format     string
+	Language   string
+	Visibility string
+	Burn       bool
+	Protected  bool
+	Encrypted  bool
+	Tags       []string
+	Created    time.Time
+	Expires    time.Time
+	Deleted    time.Time
 }
 
 type NoteModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(note *Note, password string) (int, error)
 	Get(id int) (*Note, error)
+	GetBySlug(slug string) (*Note, error)
 	Latest() ([]*Note, error)
+	Update(note *Note) error
+	Extend(id int, expires time.Time) error
+	Delete(id int) error
+	Trash(userID int) ([]*Note, error)
+	Restore(id, userID int) error
+	Purge(id, userID int) error
+	PurgeDeleted(retention time.Duration, limit int) (int, error)
+	Burn(id int) error
+	PurgeExpired(grace time.Duration, limit int) (int, error)
+	Unlock(id int, password string) error
+	Revisions(id int) ([]*Revision, error)
+	Search(query string, page int) ([]*Note, error)
+	List(before, after, limit int) ([]*Note, error)
+	ByTag(tag string) ([]*Note, error)
 }
 
+// Maximum number of notes returned for each page of search results.
+const SearchPageSize = 10
+
+// Common part of every query retrieving notes, selecting the columns expected by `scanNote`. Tag
+// names are aggregated into a single comma-separated column.
+const selectNotes = `
+	SELECT n.id, n.slug, n.user_id, u.name, n.title, n.content, n.format, n.language, n.visibility, n.burn, n.hashed_password IS NOT NULL, n.encrypted, n.created, n.expires, n.deleted_at,
+		(
+			SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ',')
+			FROM note_tag nt
+			INNER JOIN tag t ON t.id = nt.tag_id
+			WHERE nt.note_id = n.id
+		)
+	FROM note n
+	INNER JOIN user u ON u.id = n.user_id
+`
+
 type NoteModel struct {
 	DB *sql.DB
 }
 
-func (m *NoteModel) Insert(title string, content string, expires int) (int, error) {
+// Stores a new note, owned by `note.UserID` and expiring at `note.Expires`. The note is protected by
+// `password` unless it's blank. The identifier generated for its URL is assigned to `note.Slug`.
+func (m *NoteModel) Insert(note *Note, password string) (int, error) {
+	var hashedPassword []byte
+
+	if password != "" {
+		var err error
+
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The note and its first revision are either both stored or not at all.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	stmt := `
-		INSERT INTO note (title, content, created, expires)
-		VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))
+		INSERT INTO note (slug, user_id, title, content, format, language, visibility, burn, hashed_password, encrypted, created, expires)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)
 	`
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	var result sql.Result
+
+	// Although very unlikely, a slug may already be taken, in which case a new one is generated.
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return 0, err
+		}
+
+		result, err = tx.Exec(stmt, slug, note.UserID, note.Title, note.Content, note.Format, note.Language, note.Visibility, note.Burn, hashedPassword, note.Encrypted, expiresAt(note.Expires))
+		if err == nil {
+			note.Slug = slug
+			break
+		}
+
+		var mySQLError *mysql.MySQLError
+		if attempt == maxSlugAttempts || !errors.As(err, &mySQLError) ||
+			mySQLError.Number != 1062 || !strings.Contains(mySQLError.Message, "note_uc_slug") {
+			return 0, err
+		}
+	}
+
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
 
-	id, err := result.LastInsertId()
+	err = setTags(tx, int(id), note.Tags)
 	if err != nil {
 		return 0, err
 	}
+
+	err = insertRevision(tx, int(id))
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
 	// Conversion from int64.
 	return int(id), nil
 }
 
+// Returns the unexpired note with the given ID, whatever its visibility, which must be enforced
+// by the caller.
 func (m *NoteModel) Get(id int) (*Note, error) {
-	stmt := `
-		SELECT id, title, content, created, expires
-		FROM note
-		WHERE expires > UTC_TIMESTAMP() AND id = ?
-	`
-	// Returns a pointer to `sql.Row`.
-	row := m.DB.QueryRow(stmt, id)
+	stmt := selectNotes + `WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.id = ?`
 
-	n := &Note{}
+	// The author's name is retrieved alongside the note so it can be displayed without an extra
+	// query. Returns a pointer to `sql.Row`.
+	row := m.DB.QueryRow(stmt, id)
 
-	err := row.Scan(&n.ID, &n.Title, &n.Content, &n.Created, &n.Expires)
+	n, err := scanNote(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -65,12 +180,26 @@ func (m *NoteModel) Get(id int) (*Note, error) {
 	return n, nil
 }
 
+// Returns the unexpired note with the given slug, whatever its visibility, which must be enforced
+// by the caller.
+func (m *NoteModel) GetBySlug(slug string) (*Note, error) {
+	stmt := selectNotes + `WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.slug = ?`
+
+	n, err := scanNote(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return n, nil
+}
+
 func (m *NoteModel) Latest() ([]*Note, error) {
-	stmt := `
-		SELECT id, title, content, created, expires
-		FROM note
-		WHERE expires > UTC_TIMESTAMP()
-		ORDER BY id
+	stmt := selectNotes + `
+		WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn
+		ORDER BY n.id
 		DESC LIMIT 10
 	`
 	rows, err := m.DB.Query(stmt)
@@ -80,12 +209,119 @@ func (m *NoteModel) Latest() ([]*Note, error) {
 	// Ensures the resultset is always properly closed before the method returns.
 	defer rows.Close()
 
+	return scanNotes(rows)
+}
+
+// Returns the unexpired public notes matching the query, ranked by relevance. Encrypted notes are
+// left out, since their content can't be matched, and so are password-protected ones, whose
+// content would otherwise be shown in the results without the password. Results are split in pages
+// of `SearchPageSize` notes, the first one being page 1.
+func (m *NoteModel) Search(query string, page int) ([]*Note, error) {
+	stmt := selectNotes + `
+		WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn AND NOT n.encrypted
+		AND n.hashed_password IS NULL
+		AND MATCH(n.title, n.content) AGAINST(? IN NATURAL LANGUAGE MODE)
+		ORDER BY MATCH(n.title, n.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, n.id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := m.DB.Query(stmt, query, query, SearchPageSize, (page-1)*SearchPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotes(rows)
+}
+
+// Returns up to `limit` unexpired public notes, from the newest to the oldest, using their IDs as cursors.
+// When `before` is non-zero, only the notes preceding it are considered, whereas when `after` is
+// non-zero, only the ones following it, closest first.
+func (m *NoteModel) List(before, after, limit int) ([]*Note, error) {
+	var stmt string
+	var args []any
+
+	switch {
+	case after > 0:
+		// Notes closest to the cursor are retrieved first, being reversed below.
+		stmt = selectNotes + `
+			WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn AND n.id > ?
+			ORDER BY n.id
+			LIMIT ?
+		`
+		args = []any{after, limit}
+	case before > 0:
+		stmt = selectNotes + `
+			WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn AND n.id < ?
+			ORDER BY n.id DESC
+			LIMIT ?
+		`
+		args = []any{before, limit}
+	default:
+		stmt = selectNotes + `
+			WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn
+			ORDER BY n.id DESC
+			LIMIT ?
+		`
+		args = []any{limit}
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes, err := scanNotes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if after > 0 {
+		for i, j := 0, len(notes)-1; i < j; i, j = i+1, j-1 {
+			notes[i], notes[j] = notes[j], notes[i]
+		}
+	}
+
+	return notes, nil
+}
+
+// Implemented by both `sql.Row` and `sql.Rows`.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Reads a note from a row whose columns match the ones listed in `selectNotes`.
+func scanNote(row scanner) (*Note, error) {
+	n := &Note{}
+	// Notes without any tags have a NULL aggregate.
+	var tags sql.NullString
+	var expires, deleted sql.NullTime
+
+	err := row.Scan(&n.ID, &n.Slug, &n.UserID, &n.Author, &n.Title, &n.Content, &n.Format, &n.Language, &n.Visibility, &n.Burn, &n.Protected, &n.Encrypted, &n.Created, &expires, &deleted, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags.Valid {
+		n.Tags = strings.Split(tags.String, ",")
+	}
+	// Left as the zero time for notes which never expire.
+	if expires.Valid {
+		n.Expires = expires.Time
+	}
+	if deleted.Valid {
+		n.Deleted = deleted.Time
+	}
+
+	return n, nil
+}
+
+// Reads every note from a resultset whose columns match the ones listed in `selectNotes`.
+func scanNotes(rows *sql.Rows) ([]*Note, error) {
 	notes := []*Note{}
 
 	for rows.Next() {
-		n := &Note{}
-
-		err = rows.Scan(&n.ID, &n.Title, &n.Content, &n.Created, &n.Expires)
+		n, err := scanNote(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -93,9 +329,233 @@ func (m *NoteModel) Latest() ([]*Note, error) {
 		notes = append(notes, n)
 	}
 	// The iteration may not have completed successfully over the whole resultset.
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
 	return notes, nil
 }
+
+// Replaces the contents, visibility and tags of the note identified by `note.ID`.
+func (m *NoteModel) Update(note *Note) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		UPDATE note SET title = ?, content = ?, format = ?, language = ?, visibility = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+	_, err = tx.Exec(stmt, note.Title, note.Content, note.Format, note.Language, note.Visibility, note.ID)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, note.ID, note.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, note.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Changes when the unexpired note with the given ID expires, the zero time meaning it never does.
+func (m *NoteModel) Extend(id int, expires time.Time) error {
+	stmt := `UPDATE note SET expires = ? WHERE id = ? AND deleted_at IS NULL AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, expiresAt(expires), id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Converts the expiry of a note into the value of its column, which is NULL when it never expires.
+func expiresAt(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// Moves the note with the given ID to the trash, from which it can still be restored.
+func (m *NoteModel) Delete(id int) error {
+	stmt := `UPDATE note SET deleted_at = UTC_TIMESTAMP() WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Returns the unexpired notes in the trash of the given user, the most recently deleted first.
+func (m *NoteModel) Trash(userID int) ([]*Note, error) {
+	stmt := selectNotes + `
+		WHERE n.user_id = ? AND n.deleted_at IS NOT NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP())
+		ORDER BY n.deleted_at DESC, n.id DESC
+	`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotes(rows)
+}
+
+// Takes the unexpired note with the given ID out of the trash of its owner, `userID`.
+func (m *NoteModel) Restore(id, userID int) error {
+	stmt := `
+		UPDATE note SET deleted_at = NULL
+		WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL AND (expires IS NULL OR expires > UTC_TIMESTAMP())
+	`
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Permanently deletes the note with the given ID from the trash of its owner, `userID`.
+func (m *NoteModel) Purge(id, userID int) error {
+	stmt := `DELETE FROM note WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Permanently deletes up to `limit` notes which were moved to the trash more than `retention` ago,
+// returning how many were deleted.
+func (m *NoteModel) PurgeDeleted(retention time.Duration, limit int) (int, error) {
+	stmt := `DELETE FROM note WHERE deleted_at < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, int(retention.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// Deletes the unexpired burn-after-reading note with the given ID. Only one caller can succeed for
+// a given note, which is how concurrent readers are prevented from both seeing its content: every
+// other caller gets `ErrNoRecord`.
+func (m *NoteModel) Burn(id int) error {
+	stmt := `DELETE FROM note WHERE id = ? AND burn AND deleted_at IS NULL AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Permanently deletes up to `limit` notes which expired more than `grace` ago, along with their
+// revisions and tags, returning how many were deleted.
+func (m *NoteModel) PurgeExpired(grace time.Duration, limit int) (int, error) {
+	stmt := `DELETE FROM note WHERE expires < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND) LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, int(grace.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// Checks the password of the unexpired protected note with the given ID, returning
+// `ErrInvalidCredentials` if it doesn't match.
+func (m *NoteModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := `
+		SELECT hashed_password FROM note
+		WHERE id = ? AND hashed_password IS NOT NULL AND deleted_at IS NULL AND (expires IS NULL OR expires > UTC_TIMESTAMP())
+	`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
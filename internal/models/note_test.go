@@ -0,0 +1,408 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestNoteModelGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name       string
+		noteID     int
+		wantAuthor string
+		wantErr    error
+	}{
+		{
+			name:       "Valid ID",
+			noteID:     1,
+			wantAuthor: "Alice Jones",
+		},
+		{
+			name:    "Non-Existent ID",
+			noteID:  2,
+			wantErr: ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := NoteModel{db}
+
+			note, err := m.Get(tt.noteID)
+
+			assert.Equal(t, err, tt.wantErr)
+
+			if err == nil {
+				assert.Equal(t, note.UserID, 1)
+				assert.Equal(t, note.Author, tt.wantAuthor)
+			}
+		})
+	}
+}
+
+func TestNoteModelGetBySlug(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		slug    string
+		wantID  int
+		wantErr error
+	}{
+		{
+			name:   "Valid slug",
+			slug:   "pond000001",
+			wantID: 1,
+		},
+		{
+			name:    "Non-Existent slug",
+			slug:    "pond000002",
+			wantErr: ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := NoteModel{db}
+
+			note, err := m.GetBySlug(tt.slug)
+
+			assert.Equal(t, err, tt.wantErr)
+
+			if err == nil {
+				assert.Equal(t, note.ID, tt.wantID)
+				assert.Equal(t, note.Slug, tt.slug)
+			}
+		})
+	}
+}
+
+func TestNoteModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		page      int
+		wantCount int
+	}{
+		{
+			name:      "Matching query",
+			query:     "pond",
+			page:      1,
+			wantCount: 1,
+		},
+		{
+			name:      "No match",
+			query:     "frog",
+			page:      1,
+			wantCount: 0,
+		},
+		{
+			name:      "Beyond last page",
+			query:     "pond",
+			page:      2,
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := NoteModel{db}
+
+			notes, err := m.Search(tt.query, tt.page)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(notes), tt.wantCount)
+		})
+	}
+}
+
+func TestNoteModelList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		before    int
+		after     int
+		wantCount int
+	}{
+		{
+			name:      "No cursor",
+			wantCount: 1,
+		},
+		{
+			name:      "Before the only note",
+			before:    1,
+			wantCount: 0,
+		},
+		{
+			name:      "After the only note",
+			after:     1,
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := NoteModel{db}
+
+			notes, err := m.List(tt.before, tt.after, 10)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(notes), tt.wantCount)
+		})
+	}
+}
+
+func TestNoteModelByTag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		tag       string
+		wantCount int
+	}{
+		{
+			name:      "Existing tag",
+			tag:       "haiku",
+			wantCount: 1,
+		},
+		{
+			name:      "Non-existent tag",
+			tag:       "prose",
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := NoteModel{db}
+
+			notes, err := m.ByTag(tt.tag)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(notes), tt.wantCount)
+		})
+	}
+}
+
+func TestNoteModelBurn(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	note := &Note{
+		UserID:     1,
+		Title:      "Recovery codes",
+		Content:    "1234-5678",
+		Format:     FormatPlain,
+		Visibility: VisibilityUnlisted,
+		Burn:       true,
+		Expires:    time.Now().Add(time.Hour),
+	}
+
+	id, err := m.Insert(note, "")
+	assert.NilError(t, err)
+
+	// Only the first reader gets to burn the note.
+	assert.NilError(t, m.Burn(id))
+	assert.Equal(t, m.Burn(id), ErrNoRecord)
+
+	_, err = m.Get(id)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Notes without the flag can't be burned.
+	assert.Equal(t, m.Burn(1), ErrNoRecord)
+}
+
+func TestNoteModelUnlock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	note := &Note{
+		UserID:     1,
+		Title:      "Wi-Fi",
+		Content:    "correct horse battery staple",
+		Format:     FormatPlain,
+		Visibility: VisibilityUnlisted,
+		Expires:    time.Now().Add(time.Hour),
+	}
+
+	id, err := m.Insert(note, "pa$$word")
+	assert.NilError(t, err)
+
+	note, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, note.Protected, true)
+
+	assert.NilError(t, m.Unlock(id, "pa$$word"))
+	assert.Equal(t, m.Unlock(id, "password"), ErrInvalidCredentials)
+
+	// Notes without a password can't be unlocked.
+	assert.Equal(t, m.Unlock(1, "pa$$word"), ErrNoRecord)
+}
+
+func TestNoteModelExtend(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	expires := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NilError(t, m.Extend(1, expires))
+
+	note, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, note.Expires, expires)
+
+	// The zero time makes the note never expire.
+	assert.NilError(t, m.Extend(1, time.Time{}))
+
+	note, err = m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, note.Expires.IsZero(), true)
+
+	assert.Equal(t, m.Extend(2, expires), ErrNoRecord)
+}
+
+func TestNoteModelPurgeExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	for i := 0; i < 3; i++ {
+		note := &Note{
+			UserID:     1,
+			Title:      "Expired",
+			Content:    "Expired",
+			Format:     FormatPlain,
+			Visibility: VisibilityPublic,
+			Expires:    time.Now().Add(-2 * time.Hour),
+		}
+
+		_, err := m.Insert(note, "")
+		assert.NilError(t, err)
+	}
+
+	// Notes which expired within the grace period are kept.
+	n, err := m.PurgeExpired(3*time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	n, err = m.PurgeExpired(time.Hour, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	n, err = m.PurgeExpired(time.Hour, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// The unexpired note is left untouched.
+	_, err = m.Get(1)
+	assert.NilError(t, err)
+}
+
+func TestNoteModelTrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	assert.NilError(t, m.Delete(1))
+	assert.Equal(t, m.Delete(1), ErrNoRecord)
+
+	// Notes in the trash are left out everywhere else.
+	_, err := m.Get(1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	notes, err := m.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(notes), 1)
+	assert.Equal(t, notes[0].Deleted.IsZero(), false)
+
+	// Only the owner of a note can restore it.
+	assert.Equal(t, m.Restore(1, 2), ErrNoRecord)
+	assert.NilError(t, m.Restore(1, 1))
+
+	_, err = m.Get(1)
+	assert.NilError(t, err)
+
+	// Notes must be in the trash to be purged.
+	assert.Equal(t, m.Purge(1, 1), ErrNoRecord)
+
+	assert.NilError(t, m.Delete(1))
+	assert.NilError(t, m.Purge(1, 1))
+
+	notes, err = m.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(notes), 0)
+}
+
+func TestNoteModelPurgeDeleted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := NoteModel{db}
+
+	assert.NilError(t, m.Delete(1))
+
+	// Notes are kept in the trash during the retention period.
+	n, err := m.PurgeDeleted(time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	_, err = db.Exec("UPDATE note SET deleted_at = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 2 HOUR) WHERE id = 1")
+	assert.NilError(t, err)
+
+	n, err = m.PurgeDeleted(time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Snapshot of a note's contents, recorded every time the note is created or updated. `Number` is
+// the position of the revision in the note's history, starting at 1.
+type Revision struct {
+	ID       int
+	NoteID   int
+	Number   int
+	UserID   int
+	Author   string
+	Title    string
+	Content  string
+	Format   string
+	Language string
+	Created  time.Time
+}
+
+// Records the current state of the note as a new revision, as part of the given transaction.
+func insertRevision(tx *sql.Tx, noteID int) error {
+	stmt := `
+		INSERT INTO note_revision (note_id, user_id, title, content, format, language, created)
+		SELECT id, user_id, title, content, format, language, UTC_TIMESTAMP()
+		FROM note
+		WHERE id = ?
+	`
+	_, err := tx.Exec(stmt, noteID)
+	return err
+}
+
+// Returns every revision of the note, from the oldest to the most recent one.
+func (m *NoteModel) Revisions(id int) ([]*Revision, error) {
+	stmt := `
+		SELECT r.id, r.note_id, r.user_id, u.name, r.title, r.content, r.format, r.language, r.created
+		FROM note_revision r
+		INNER JOIN user u ON u.id = r.user_id
+		WHERE r.note_id = ?
+		ORDER BY r.id
+	`
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		r := &Revision{Number: len(revisions) + 1}
+
+		err = rows.Scan(&r.ID, &r.NoteID, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Format, &r.Language, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Session in which a user is logged in, or waiting to enter the code of their authenticator app,
+// as recorded in the index of the sessions of each user. Only a hash of the session token is
+// stored, which also identifies the session without disclosing the token.
+type Session struct {
+	ID     string
+	UserID int
+	// Whether the user still has to enter the code of their authenticator app.
+	Pending   bool
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+}
+
+type SessionModelInterface interface {
+	Insert(token string, userID int, pending bool, ip, userAgent string) error
+	Get(token string) (*Session, error)
+	Touch(token, ip string) error
+	Renew(oldToken, newToken string) error
+	Delete(token string) error
+	ForUser(userID int) ([]*Session, error)
+	Revoke(id string, userID int) error
+	RevokeAll(userID int, exceptToken string) error
+}
+
+// Indexes the sessions by the users they belong to, so that the ones of a user can be listed and
+// revoked without going through the sessions of every other user. Entries expire along with the
+// sessions, after `Lifetime`.
+type SessionModel struct {
+	DB       *sql.DB
+	Lifetime time.Duration
+}
+
+// Records the session of the token, clearing the expired ones of the user along the way.
+func (m *SessionModel) Insert(token string, userID int, pending bool, ip, userAgent string) error {
+	_, err := m.DB.Exec(`DELETE FROM user_session WHERE user_id = ? AND expires <= UTC_TIMESTAMP()`, userID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		INSERT INTO user_session (hash, user_id, pending, ip, user_agent, created, last_seen, expires)
+		VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))
+	`
+	_, err = m.DB.Exec(stmt, hashToken(token), userID, pending, ip, userAgent, int(m.Lifetime.Seconds()))
+	return err
+}
+
+// Returns the unexpired session of the token, or `ErrNoRecord` if it was never recorded or was
+// revoked since.
+func (m *SessionModel) Get(token string) (*Session, error) {
+	stmt := `
+		SELECT hash, user_id, pending, ip, user_agent, created, last_seen FROM user_session
+		WHERE hash = ? AND expires > UTC_TIMESTAMP()
+	`
+	s, err := scanSession(m.DB.QueryRow(stmt, hashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Records that the session was seen right now, from the given address.
+func (m *SessionModel) Touch(token, ip string) error {
+	stmt := `UPDATE user_session SET last_seen = UTC_TIMESTAMP(), ip = ? WHERE hash = ?`
+
+	_, err := m.DB.Exec(stmt, ip, hashToken(token))
+	return err
+}
+
+// Moves the session to the new token it was given.
+func (m *SessionModel) Renew(oldToken, newToken string) error {
+	stmt := `UPDATE user_session SET hash = ? WHERE hash = ?`
+
+	_, err := m.DB.Exec(stmt, hashToken(newToken), hashToken(oldToken))
+	return err
+}
+
+func (m *SessionModel) Delete(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM user_session WHERE hash = ?`, hashToken(token))
+	return err
+}
+
+// Returns the unexpired sessions in which the user is logged in, the most recently seen first.
+func (m *SessionModel) ForUser(userID int) ([]*Session, error) {
+	stmt := `
+		SELECT hash, user_id, pending, ip, user_agent, created, last_seen FROM user_session
+		WHERE user_id = ? AND NOT pending AND expires > UTC_TIMESTAMP()
+		ORDER BY last_seen DESC
+	`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Deletes the session with the given ID, as long as it belongs to `userID`.
+func (m *SessionModel) Revoke(id string, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM user_session WHERE hash = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Deletes every session of the user, including the pending ones, except for the session of
+// `exceptToken`, if given.
+func (m *SessionModel) RevokeAll(userID int, exceptToken string) error {
+	stmt := `DELETE FROM user_session WHERE user_id = ? AND hash <> ?`
+
+	_, err := m.DB.Exec(stmt, userID, hashToken(exceptToken))
+	return err
+}
+
+func scanSession(row scanner) (*Session, error) {
+	s := &Session{}
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Pending, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestSessionModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SessionModel{DB: db, Lifetime: 12 * time.Hour}
+
+	err := m.Insert("laptop", 1, false, "192.0.2.1", "Firefox")
+	assert.NilError(t, err)
+
+	err = m.Insert("phone", 1, false, "192.0.2.2", "Safari")
+	assert.NilError(t, err)
+
+	err = m.Insert("pending", 1, true, "192.0.2.3", "Chrome")
+	assert.NilError(t, err)
+
+	s, err := m.Get("laptop")
+	assert.NilError(t, err)
+	assert.Equal(t, s.UserID, 1)
+	assert.Equal(t, s.IP, "192.0.2.1")
+	assert.Equal(t, s.UserAgent, "Firefox")
+
+	_, err = m.Get("unknown")
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Pending sessions aren't listed.
+	sessions, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 2)
+
+	t.Run("Touch", func(t *testing.T) {
+		err := m.Touch("laptop", "192.0.2.4")
+		assert.NilError(t, err)
+
+		s, err := m.Get("laptop")
+		assert.NilError(t, err)
+		assert.Equal(t, s.IP, "192.0.2.4")
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		err := m.Renew("laptop", "laptop2")
+		assert.NilError(t, err)
+
+		_, err = m.Get("laptop")
+		assert.Equal(t, err, ErrNoRecord)
+
+		_, err = m.Get("laptop2")
+		assert.NilError(t, err)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		s, err := m.Get("phone")
+		assert.NilError(t, err)
+
+		err = m.Revoke(s.ID, 2)
+		assert.Equal(t, err, ErrNoRecord)
+
+		err = m.Revoke(s.ID, 1)
+		assert.NilError(t, err)
+
+		_, err = m.Get("phone")
+		assert.Equal(t, err, ErrNoRecord)
+	})
+
+	t.Run("Revoke all", func(t *testing.T) {
+		err := m.RevokeAll(1, "laptop2")
+		assert.NilError(t, err)
+
+		_, err = m.Get("pending")
+		assert.Equal(t, err, ErrNoRecord)
+
+		sessions, err := m.ForUser(1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 1)
+	})
+}
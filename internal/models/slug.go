@@ -0,0 +1,41 @@
+package models
+
+import (
+	"crypto/rand"
+)
+
+const (
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	slugLength   = 10
+	// Number of slugs generated for a note before giving up on inserting it.
+	maxSlugAttempts = 3
+)
+
+// Generates a random identifier made of base62 characters, used in the URL of a note instead of
+// its sequential ID, which could be easily enumerated.
+func newSlug() (string, error) {
+	slug := make([]byte, 0, slugLength)
+	buf := make([]byte, slugLength)
+
+	for len(slug) < slugLength {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			// Bytes past the largest multiple of the alphabet size are discarded, otherwise the
+			// first characters of the alphabet would be more likely to be picked.
+			if int(b) >= 256-256%len(slugAlphabet) {
+				continue
+			}
+
+			slug = append(slug, slugAlphabet[int(b)%len(slugAlphabet)])
+			if len(slug) == slugLength {
+				break
+			}
+		}
+	}
+
+	return string(slug), nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+	"github.com/gustavodiasag/notebox/internal/validator"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 100; i++ {
+		slug, err := newSlug()
+
+		assert.NilError(t, err)
+		assert.Equal(t, validator.Matches(slug, validator.SlugRX), true)
+		assert.Equal(t, seen[slug], false)
+
+		seen[slug] = true
+	}
+}
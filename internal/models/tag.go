@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Replaces the tags of the note, as part of the given transaction. Tags which haven't been used
+// before are created along the way.
+func setTags(tx *sql.Tx, noteID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM note_tag WHERE note_id = ?`, noteID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// Whenever the tag already exists, `LAST_INSERT_ID(id)` makes its ID available through
+		// `LastInsertId`, just as if it had been inserted.
+		stmt := `INSERT INTO tag (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`
+
+		result, err := tx.Exec(stmt, tag)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO note_tag (note_id, tag_id) VALUES(?, ?)`, noteID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns every unexpired public note with the given tag, from the newest to the oldest.
+func (m *NoteModel) ByTag(tag string) ([]*Note, error) {
+	stmt := selectNotes + `
+		INNER JOIN note_tag nt ON nt.note_id = n.id
+		INNER JOIN tag t ON t.id = nt.tag_id
+		WHERE n.deleted_at IS NULL AND (n.expires IS NULL OR n.expires > UTC_TIMESTAMP()) AND n.visibility = 'public' AND NOT n.burn AND t.name = ?
+		ORDER BY n.id DESC
+	`
+	rows, err := m.DB.Query(stmt, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotes(rows)
+}
@@ -1,26 +1,174 @@
+CREATE TABLE user (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  hashed_password CHAR(60) NOT NULL,
+  verified BOOLEAN NOT NULL DEFAULT FALSE,
+  created DATETIME NOT NULL
+);
+
+ALTER TABLE user ADD CONSTRAINT user_uc_email UNIQUE (email);
+
+CREATE TABLE user_token (
+  hash CHAR(64) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  purpose VARCHAR(20) NOT NULL,
+  expires DATETIME NOT NULL
+);
+
+ALTER TABLE user_token ADD CONSTRAINT user_token_fk_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE;
+
 CREATE TABLE note (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  slug CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+  user_id INTEGER NOT NULL,
   title VARCHAR(100) NOT NULL,
   content TEXT NOT NULL,
+  format VARCHAR(20) NOT NULL DEFAULT 'plain',
+  language VARCHAR(50) NOT NULL DEFAULT '',
+  visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+  burn BOOLEAN NOT NULL DEFAULT FALSE,
+  hashed_password CHAR(60),
+  encrypted BOOLEAN NOT NULL DEFAULT FALSE,
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  expires DATETIME,
+  deleted_at DATETIME
 );
 
 CREATE INDEX idx_note_created ON note(created);
 
-CREATE TABLE user (
+CREATE INDEX idx_note_expires ON note(expires);
+
+CREATE INDEX idx_note_deleted_at ON note(deleted_at);
+
+ALTER TABLE note ADD CONSTRAINT note_uc_slug UNIQUE (slug);
+
+CREATE FULLTEXT INDEX idx_note_fulltext ON note(title, content);
+
+ALTER TABLE note ADD CONSTRAINT note_fk_user FOREIGN KEY (user_id) REFERENCES user(id);
+
+CREATE TABLE tag (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-  name VARCHAR(255) NOT NULL,
-  email VARCHAR(255) NOT NULL,
-  hashed_password CHAR(60) NOT NULL,
+  name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tag ADD CONSTRAINT tag_uc_name UNIQUE (name);
+
+CREATE TABLE note_tag (
+  note_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (note_id, tag_id)
+);
+
+ALTER TABLE note_tag ADD CONSTRAINT note_tag_fk_note FOREIGN KEY (note_id) REFERENCES note(id) ON DELETE CASCADE;
+
+ALTER TABLE note_tag ADD CONSTRAINT note_tag_fk_tag FOREIGN KEY (tag_id) REFERENCES tag(id);
+
+CREATE TABLE note_revision (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  note_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
+  format VARCHAR(20) NOT NULL DEFAULT 'plain',
+  language VARCHAR(50) NOT NULL DEFAULT '',
   created DATETIME NOT NULL
 );
 
-ALTER TABLE user ADD CONSTRAINT user_uc_email UNIQUE (email);
+ALTER TABLE note_revision ADD CONSTRAINT note_revision_fk_note FOREIGN KEY (note_id) REFERENCES note(id) ON DELETE CASCADE;
 
-INSERT INTO user (name, email, hashed_password, created) VALUES (
+ALTER TABLE note_revision ADD CONSTRAINT note_revision_fk_user FOREIGN KEY (user_id) REFERENCES user(id);
+
+CREATE TABLE token (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  scope VARCHAR(10) NOT NULL,
+  created DATETIME NOT NULL,
+  last_used DATETIME
+);
+
+ALTER TABLE token ADD CONSTRAINT token_uc_hash UNIQUE (hash);
+
+ALTER TABLE token ADD CONSTRAINT token_fk_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE;
+
+CREATE TABLE totp (
+  user_id INTEGER NOT NULL PRIMARY KEY,
+  secret VARBINARY(255) NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step BIGINT NOT NULL DEFAULT 0
+);
+
+ALTER TABLE totp ADD CONSTRAINT totp_fk_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE;
+
+CREATE TABLE recovery_code (
+  user_id INTEGER NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (user_id, hash)
+);
+
+ALTER TABLE recovery_code ADD CONSTRAINT recovery_code_fk_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE;
+
+CREATE TABLE lockout (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  kind VARCHAR(10) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  locked_until DATETIME NOT NULL,
+  created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_lockout_subject ON lockout(subject);
+
+CREATE TABLE user_session (
+  hash CHAR(64) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  pending BOOLEAN NOT NULL DEFAULT FALSE,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(200) NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_session_user_id ON user_session(user_id);
+
+ALTER TABLE user_session ADD CONSTRAINT user_session_fk_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE;
+
+INSERT INTO user (name, email, hashed_password, verified, created) VALUES (
   'Alice Jones',
   'alice@example.com',
   '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+  TRUE,
+  '2022-01-01 10:00:00'
+);
+
+INSERT INTO note (slug, user_id, title, content, created, expires) VALUES (
+  'pond000001',
+  1,
+  'An old silent pond',
+  'An old silent pond...',
+  '2022-01-01 10:00:00',
+  '2099-01-01 10:00:00'
+);
+
+INSERT INTO note_revision (note_id, user_id, title, content, created) VALUES (
+  1,
+  1,
+  'An old silent pond',
+  'An old silent pond...',
+  '2022-01-01 10:00:00'
+);
+
+INSERT INTO tag (name) VALUES ('haiku');
+
+INSERT INTO note_tag (note_id, tag_id) VALUES (1, 1);
+
+INSERT INTO token (user_id, name, hash, scope, created) VALUES (
+  1,
+  'CI',
+  '4dcd8c45862ff301310b0a21d9f8892cdce1e1405bb3da0b22c93477515f587b',
+  'read',
   '2022-01-01 10:00:00'
 );
@@ -1,3 +1,21 @@
-DROP TABLE user;
+DROP TABLE note_revision;
+
+DROP TABLE note_tag;
+
+DROP TABLE tag;
 
 DROP TABLE note;
+
+DROP TABLE token;
+
+DROP TABLE user_token;
+
+DROP TABLE totp;
+
+DROP TABLE recovery_code;
+
+DROP TABLE lockout;
+
+DROP TABLE user_session;
+
+DROP TABLE user;
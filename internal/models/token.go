@@ -0,0 +1,171 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Scopes of a token, read-only tokens being unable to change any data on behalf of their owners.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Prefix of every token, making it easier to recognize them, for example when leaked in logs.
+const tokenPrefix = "nb_"
+
+// Personal token used by non-browser clients to authenticate against the API. Only a hash of the
+// token is stored, so it can't be recovered once created.
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Scope   string
+	Created time.Time
+	// Zero if the token was never used.
+	LastUsed time.Time
+}
+
+type TokenModelInterface interface {
+	Insert(userID int, name, scope string) (string, error)
+	ForUser(userID int) ([]*Token, error)
+	Authenticate(plaintext string) (*Token, error)
+	Revoke(id, userID int) error
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Generates a random token with 256 bits of entropy, safe to be used in URLs.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Tokens are random enough for a fast hash to be safe, which, unlike bcrypt, allows them to be
+// looked up by their hashes.
+func hashToken(plaintext string) string {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(hash[:])
+}
+
+// Creates a token for the user, returning its plaintext, which must be shown to the user right
+// away as it isn't stored.
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	random, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := tokenPrefix + random
+
+	stmt := `
+		INSERT INTO token (user_id, name, hash, scope, created)
+		VALUES (?, ?, ?, ?, UTC_TIMESTAMP())
+	`
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(plaintext), scope)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Returns every token of the user, most recently created first.
+func (m *TokenModel) ForUser(userID int) ([]*Token, error) {
+	stmt := `
+		SELECT id, user_id, name, scope, created, last_used FROM token
+		WHERE user_id = ?
+		ORDER BY id DESC
+	`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Returns the token matching the plaintext, recording that it was used. `ErrInvalidCredentials` is
+// returned if there's no such token.
+func (m *TokenModel) Authenticate(plaintext string) (*Token, error) {
+	stmt := `
+		SELECT id, user_id, name, scope, created, last_used FROM token
+		WHERE hash = ?
+	`
+	t, err := scanToken(m.DB.QueryRow(stmt, hashToken(plaintext)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	_, err = m.DB.Exec(`UPDATE token SET last_used = UTC_TIMESTAMP() WHERE id = ?`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Deletes the token with the given ID, as long as it belongs to `userID`.
+func (m *TokenModel) Revoke(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM token WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func scanToken(row scanner) (*Token, error) {
+	t := &Token{}
+
+	var lastUsed sql.NullTime
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+)
+
+func TestTokenModelAuthenticate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+		wantID    int
+		wantErr   error
+	}{
+		{
+			name:      "Valid token",
+			plaintext: "nb_seedtoken",
+			wantID:    1,
+		},
+		{
+			name:      "Invalid token",
+			plaintext: "nb_wrongtoken",
+			wantErr:   ErrInvalidCredentials,
+		},
+		{
+			name:      "Empty token",
+			plaintext: "",
+			wantErr:   ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := TokenModel{db}
+
+			token, err := m.Authenticate(tt.plaintext)
+
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, token.ID, tt.wantID)
+				assert.Equal(t, token.Scope, ScopeRead)
+			}
+		})
+	}
+}
+
+func TestTokenModelInsert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := TokenModel{db}
+
+	plaintext, err := m.Insert(1, "Deploy", ScopeWrite)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(plaintext, tokenPrefix), true)
+
+	token, err := m.Authenticate(plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, token.Name, "Deploy")
+	assert.Equal(t, token.Scope, ScopeWrite)
+
+	tokens, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 2)
+	// Only tokens which were used have a last use.
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), false)
+	assert.Equal(t, tokens[1].LastUsed.IsZero(), true)
+}
+
+func TestTokenModelRevoke(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := TokenModel{db}
+
+	// Tokens of other users can't be revoked.
+	err := m.Revoke(1, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Revoke(1, 1)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate("nb_seedtoken")
+	assert.Equal(t, err, ErrInvalidCredentials)
+}
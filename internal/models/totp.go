@@ -0,0 +1,311 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// Settings shared by every authenticator app, which the codes are generated with.
+var totpOpts = totp.ValidateOpts{
+	Period:    30,
+	Skew:      1,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// Number of recovery codes given to users once they enable two-factor authentication.
+const recoveryCodeCount = 10
+
+type TOTPModelInterface interface {
+	Setup(userID int, secret string) error
+	Pending(userID int) (string, error)
+	Enable(userID int, code string) ([]string, error)
+	Enabled(userID int) (bool, error)
+	Validate(userID int, code string) error
+	UseRecoveryCode(userID int, code string) error
+}
+
+// Stores the secrets of the authenticator apps of users, encrypted with `Key`, which must be 32
+// bytes long so that AES-256 is used. Without a key, `ErrNoTOTPKey` is returned by every method
+// which needs to encrypt or decrypt a secret.
+type TOTPModel struct {
+	DB  *sql.DB
+	Key []byte
+}
+
+// Stores the secret of an authenticator app being set up by the user, replacing any other one
+// which wasn't confirmed yet. Users who already enabled two-factor authentication can't set up
+// another app.
+func (m *TOTPModel) Setup(userID int, secret string) error {
+	ciphertext, err := m.encrypt([]byte(secret))
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		INSERT INTO totp (user_id, secret, enabled) VALUES (?, ?, FALSE)
+		ON DUPLICATE KEY UPDATE secret = IF(enabled, secret, VALUES(secret))
+	`
+	_, err = m.DB.Exec(stmt, userID, ciphertext)
+	return err
+}
+
+// Returns the secret of the app being set up by the user, or `ErrNoRecord` if there's none.
+func (m *TOTPModel) Pending(userID int) (string, error) {
+	var ciphertext []byte
+
+	stmt := `SELECT secret FROM totp WHERE user_id = ? AND NOT enabled`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&ciphertext)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	secret, err := m.decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// Enables two-factor authentication once the user proves the app was set up by entering one of
+// its codes, returning the recovery codes which can be used in case the app is lost. Returns
+// `ErrNoRecord` if no app is being set up and `ErrInvalidCredentials` if the code is wrong.
+func (m *TOTPModel) Enable(userID int, code string) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ciphertext []byte
+
+	stmt := `SELECT secret FROM totp WHERE user_id = ? AND NOT enabled FOR UPDATE`
+
+	err = tx.QueryRow(stmt, userID).Scan(&ciphertext)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	step, err := m.match(ciphertext, code, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE totp SET enabled = TRUE, last_step = ? WHERE user_id = ?`, step, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_code WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`INSERT INTO recovery_code (user_id, hash) VALUES (?, ?)`, userID, hashToken(code))
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, tx.Commit()
+}
+
+func (m *TOTPModel) Enabled(userID int) (bool, error) {
+	var enabled bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM totp WHERE user_id = ? AND enabled)`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&enabled)
+
+	return enabled, err
+}
+
+// Checks the code generated by the app of the user, returning `ErrInvalidCredentials` if it's
+// wrong. Each code is only accepted once, so that one seen by someone else can't be replayed while
+// it's still valid.
+func (m *TOTPModel) Validate(userID int, code string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ciphertext []byte
+	var lastStep int64
+
+	stmt := `SELECT secret, last_step FROM totp WHERE user_id = ? AND enabled FOR UPDATE`
+
+	err = tx.QueryRow(stmt, userID).Scan(&ciphertext, &lastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	step, err := m.match(ciphertext, code, lastStep)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE totp SET last_step = ? WHERE user_id = ?`, step, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Consumes one of the recovery codes of the user, returning `ErrInvalidCredentials` if it's wrong
+// or was already used.
+func (m *TOTPModel) UseRecoveryCode(userID int, code string) error {
+	stmt := `DELETE FROM recovery_code WHERE user_id = ? AND hash = ?`
+
+	result, err := m.DB.Exec(stmt, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// Returns the time step in which the code was generated from the encrypted secret, as long as it's
+// later than `after`. The codes of the steps right before and after the current one are accepted
+// as well, given that the clocks of the server and the app may differ slightly.
+func (m *TOTPModel) match(ciphertext []byte, code string, after int64) (int64, error) {
+	secret, err := m.decrypt(ciphertext)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix() / int64(totpOpts.Period)
+
+	for step := now - int64(totpOpts.Skew); step <= now+int64(totpOpts.Skew); step++ {
+		if step <= after {
+			continue
+		}
+
+		want, err := totp.GenerateCodeCustom(string(secret), time.Unix(step*int64(totpOpts.Period), 0), totpOpts)
+		if err != nil {
+			return 0, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrInvalidCredentials
+}
+
+// Encrypts the data with AES-GCM, prepending the random nonce to the result.
+func (m *TOTPModel) encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := m.cipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (m *TOTPModel) decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := m.cipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("models: malformed encrypted secret")
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (m *TOTPModel) cipher() (cipher.AEAD, error) {
+	if len(m.Key) == 0 {
+		return nil, ErrNoTOTPKey
+	}
+	if len(m.Key) != 32 {
+		return nil, errors.New("models: the TOTP encryption key must be 32 bytes long")
+	}
+
+	block, err := aes.NewCipher(m.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Generates a recovery code such as "k3j9d-7xq2m", with 50 bits of entropy.
+func randomRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+// Allows recovery codes to be entered in any case, with or without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+
+	if len(code) != 10 {
+		return code
+	}
+
+	return code[:5] + "-" + code[5:]
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gustavodiasag/notebox/internal/assert"
+	"github.com/pquerna/otp/totp"
+)
+
+var testTOTPKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestTOTPModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := TOTPModel{DB: db, Key: testTOTPKey}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "Notebox", AccountName: "alice@example.com"})
+	assert.NilError(t, err)
+
+	err = m.Setup(1, key.Secret())
+	assert.NilError(t, err)
+
+	pending, err := m.Pending(1)
+	assert.NilError(t, err)
+	assert.Equal(t, pending, key.Secret())
+
+	enabled, err := m.Enabled(1)
+	assert.NilError(t, err)
+	assert.Equal(t, enabled, false)
+
+	_, err = m.Enable(1, "000000")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	now := time.Now()
+
+	code, err := totp.GenerateCode(key.Secret(), now)
+	assert.NilError(t, err)
+
+	recoveryCodes, err := m.Enable(1, code)
+	assert.NilError(t, err)
+	assert.Equal(t, len(recoveryCodes), recoveryCodeCount)
+
+	enabled, err = m.Enabled(1)
+	assert.NilError(t, err)
+	assert.Equal(t, enabled, true)
+
+	_, err = m.Pending(1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Setting up another app doesn't replace the enabled one.
+	err = m.Setup(1, "JBSWY3DPEHPK3PXP")
+	assert.NilError(t, err)
+
+	t.Run("Replayed code", func(t *testing.T) {
+		err := m.Validate(1, code)
+		assert.Equal(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Next code", func(t *testing.T) {
+		next, err := totp.GenerateCode(key.Secret(), now.Add(30*time.Second))
+		assert.NilError(t, err)
+
+		err = m.Validate(1, next)
+		assert.NilError(t, err)
+
+		err = m.Validate(1, next)
+		assert.Equal(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		err := m.UseRecoveryCode(1, "wrong-code")
+		assert.Equal(t, err, ErrInvalidCredentials)
+
+		err = m.UseRecoveryCode(1, recoveryCodes[0])
+		assert.NilError(t, err)
+
+		err = m.UseRecoveryCode(1, recoveryCodes[0])
+		assert.Equal(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestTOTPModelEncryption(t *testing.T) {
+	m := TOTPModel{Key: testTOTPKey}
+
+	ciphertext, err := m.encrypt([]byte("JBSWY3DPEHPK3PXP"))
+	assert.NilError(t, err)
+
+	plaintext, err := m.decrypt(ciphertext)
+	assert.NilError(t, err)
+	assert.Equal(t, string(plaintext), "JBSWY3DPEHPK3PXP")
+
+	other := TOTPModel{Key: bytes.Repeat([]byte{0x24}, 32)}
+
+	_, err = other.decrypt(ciphertext)
+	if err == nil {
+		t.Error("got: nil; expected an error decrypting with another key")
+	}
+
+	_, err = (&TOTPModel{}).encrypt([]byte("JBSWY3DPEHPK3PXP"))
+	assert.Equal(t, err, ErrNoTOTPKey)
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "Canonical",
+			code: "k3j9d-7xq2m",
+			want: "k3j9d-7xq2m",
+		},
+		{
+			name: "Uppercase without dash",
+			code: " K3J9D7XQ2M ",
+			want: "k3j9d-7xq2m",
+		},
+		{
+			name: "Spaces",
+			code: "k3j9d 7xq2m",
+			want: "k3j9d-7xq2m",
+		},
+		{
+			name: "Too short",
+			code: "k3j9d",
+			want: "k3j9d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, normalizeRecoveryCode(tt.code), tt.want)
+		})
+	}
+}
@@ -15,46 +15,129 @@ type User struct {
 	Name           string
 	Email          string
 	HashedPassword []byte
+	Verified       bool
 	Created        time.Time
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, email, password string) (string, error)
 	Get(id int) (*User, error)
+	GetByEmail(email string) (*User, error)
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
 	UpdatePassword(id int, current, new string) error
+	Verify(token string) error
+	NewVerificationToken(id int) (string, error)
+	NewPasswordResetToken(id int) (string, error)
+	ResetPassword(token, password string) (int, error)
 }
 
 type UserModel struct {
 	DB *sql.DB
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+// Purposes of the single-use tokens sent to users by email.
+const (
+	purposeVerification  = "verification"
+	purposePasswordReset = "password-reset"
+)
+
+const (
+	// Time during which the link to verify an email address can be used.
+	verificationTTL = 24 * time.Hour
+	// Time during which the link to reset a password can be used.
+	passwordResetTTL = time.Hour
+)
+
+// Creates an unverified account, returning the token which verifies it.
+func (m *UserModel) Insert(name, email, password string) (string, error) {
 	// The second parameter passed to `GenerateFromPassword` indicates the number of bcrypt
 	// iterations used to generate the password hash.
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	// The account and its token are either both stored or not at all.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
 	}
+	defer tx.Rollback()
 
 	stmt := `
     INSERT INTO user (name, email, hashed_password, created)
     VALUES(?, ?, ?, UTC_TIMESTAMP())
   `
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := tx.Exec(stmt, name, email, string(hashedPassword))
 	if err != nil {
 		// Check specifically if the error generated is related to the unique email constraint.
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "user_uc_email") {
-				return ErrDuplicateEmail
+				return "", ErrDuplicateEmail
 			}
 		}
-		return err
+		return "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+
+	token, err := insertUserToken(tx, int(id), purposeVerification, verificationTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// Stores a new single-use token for the user, returning its plaintext.
+func insertUserToken(tx *sql.Tx, userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `
+		INSERT INTO user_token (hash, user_id, purpose, expires)
+		VALUES (?, ?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))
+	`
+	_, err = tx.Exec(stmt, hashToken(token), userID, purpose, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Deletes the unexpired token with the given purpose, returning the ID of the user it was sent
+// to, or `ErrNoRecord` if there's no such token.
+func consumeUserToken(tx *sql.Tx, token, purpose string) (int, error) {
+	var userID int
+
+	stmt := `
+		SELECT user_id FROM user_token
+		WHERE hash = ? AND purpose = ? AND expires > UTC_TIMESTAMP()
+		FOR UPDATE
+	`
+	err := tx.QueryRow(stmt, hashToken(token), purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	// Any other token with the same purpose is no longer needed either.
+	_, err = tx.Exec(`DELETE FROM user_token WHERE user_id = ? AND purpose = ?`, userID, purpose)
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return userID, nil
 }
 
 func (m *UserModel) Get(id int) (*User, error) {
@@ -73,13 +156,32 @@ func (m *UserModel) Get(id int) (*User, error) {
 	return &user, nil
 }
 
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	var user User
+
+	stmt := `SELECT id, name, email, verified, created FROM user WHERE email = ?`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&user.ID, &user.Name, &user.Email, &user.Verified, &user.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Returns the ID of the user with the given credentials. Users whose email address wasn't verified
+// yet are refused with `ErrUnverifiedAccount`, which is only disclosed to those with the password.
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
 	var hashedPassword []byte
+	var verified bool
 
-	stmt := `SELECT id, hashed_password FROM user WHERE email = ?`
+	stmt := `SELECT id, hashed_password, verified FROM user WHERE email = ?`
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &verified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -95,6 +197,10 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		return 0, err
 	}
 
+	if !verified {
+		return 0, ErrUnverifiedAccount
+	}
+
 	return id, nil
 }
 
@@ -136,3 +242,123 @@ func (m *UserModel) UpdatePassword(id int, current, new string) error {
 	_, err = m.DB.Exec(stmt, newHashedPassword, id)
 	return err
 }
+
+// Marks the account the verification token was sent to as verified, returning `ErrNoRecord` if the
+// token is invalid or expired.
+func (m *UserModel) Verify(token string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, purposeVerification)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE user SET verified = TRUE WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Replaces the verification token of the unverified user, returning `ErrNoRecord` if the user is
+// already verified.
+func (m *UserModel) NewVerificationToken(id int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var verified bool
+
+	err = tx.QueryRow(`SELECT verified FROM user WHERE id = ? FOR UPDATE`, id).Scan(&verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	if verified {
+		return "", ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_token WHERE user_id = ? AND purpose = ?`, id, purposeVerification)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := insertUserToken(tx, id, purposeVerification, verificationTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// Replaces any password reset token of the user, returning `ErrNoRecord` if there's no such user.
+func (m *UserModel) NewPasswordResetToken(id int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+
+	err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM user WHERE id = ? FOR UPDATE)`, id).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+
+	if !exists {
+		return "", ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_token WHERE user_id = ? AND purpose = ?`, id, purposePasswordReset)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := insertUserToken(tx, id, purposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// Replaces the password of the user the reset token was sent to, returning their ID, or
+// `ErrNoRecord` if the token is invalid or expired. Since the token was received by email, the
+// address of the user is verified as well.
+func (m *UserModel) ResetPassword(token, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(tx, token, purposePasswordReset)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `UPDATE user SET hashed_password = ?, verified = TRUE WHERE id = ?`
+
+	_, err = tx.Exec(stmt, hashedPassword, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
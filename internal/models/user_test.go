@@ -46,3 +46,110 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	token, err := m.Insert("Bob", "bob@example.com", "validpass")
+	assert.NilError(t, err)
+
+	// The password is correct, but the account can't be used until it's verified.
+	_, err = m.Authenticate("bob@example.com", "validpass")
+	assert.Equal(t, err, ErrUnverifiedAccount)
+
+	_, err = m.Authenticate("bob@example.com", "wrongpass")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	err = m.Verify("invalidtoken")
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Verify(token)
+	assert.NilError(t, err)
+
+	id, err := m.Authenticate("bob@example.com", "validpass")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 2)
+
+	// Tokens can only be used once.
+	err = m.Verify(token)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Verified users don't need new tokens.
+	_, err = m.NewVerificationToken(id)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelNewVerificationToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	first, err := m.Insert("Bob", "bob@example.com", "validpass")
+	assert.NilError(t, err)
+
+	user, err := m.GetByEmail("bob@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, false)
+
+	second, err := m.NewVerificationToken(user.ID)
+	assert.NilError(t, err)
+
+	// Only the latest token can be used.
+	err = m.Verify(first)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Verify(second)
+	assert.NilError(t, err)
+}
+
+func TestUserModelResetPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	_, err := m.NewPasswordResetToken(2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	first, err := m.NewPasswordResetToken(1)
+	assert.NilError(t, err)
+
+	second, err := m.NewPasswordResetToken(1)
+	assert.NilError(t, err)
+
+	// Only the latest token can be used.
+	_, err = m.ResetPassword(first, "newpassword")
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Verification tokens can't be used to reset passwords.
+	verification, err := m.Insert("Bob", "bob@example.com", "validpass")
+	assert.NilError(t, err)
+
+	_, err = m.ResetPassword(verification, "newpassword")
+	assert.Equal(t, err, ErrNoRecord)
+
+	id, err := m.ResetPassword(second, "newpassword")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	id, err = m.Authenticate("alice@example.com", "newpassword")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	// Tokens can only be used once.
+	_, err = m.ResetPassword(second, "otherpassword")
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -56,9 +56,35 @@ func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
 }
 
+func InRange(value, low, high int) bool {
+	return value >= low && value <= high
+}
+
 // Pattern for sanity checking the format of an email address.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// Pattern for tags, made of up to 30 lowercase letters, digits and hyphens.
+var TagRX = regexp.MustCompile("^[a-z0-9-]{1,30}$")
+
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for i := range values {
+		if !rx.MatchString(values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pattern for the random identifiers used in the URLs of notes.
+var SlugRX = regexp.MustCompile("^[0-9A-Za-z]{10}$")
+
+// Pattern for the content of encrypted notes, encoded as standard base64 and long enough to hold
+// at least the IV and the authentication tag used by AES-GCM.
+var CiphertextRX = regexp.MustCompile("^[A-Za-z0-9+/]{38,}={0,2}$")
+
+// Pattern for the codes generated by authenticator apps.
+var TOTPCodeRX = regexp.MustCompile("^[0-9]{6}$")
@@ -0,0 +1,136 @@
+// Notes are encrypted with AES-GCM, using a random 256-bit key that only ever lives in the fragment
+// of their URLs, which browsers never send to the server. The content stored by the server is the
+// base64 encoding of the IV followed by the ciphertext.
+(function () {
+	"use strict";
+
+	var ivLength = 12;
+
+	function toBase64(bytes) {
+		var binary = "";
+		for (var i = 0; i < bytes.length; i++) {
+			binary += String.fromCharCode(bytes[i]);
+		}
+		return btoa(binary);
+	}
+
+	function fromBase64(s) {
+		var binary = atob(s);
+		var bytes = new Uint8Array(binary.length);
+		for (var i = 0; i < binary.length; i++) {
+			bytes[i] = binary.charCodeAt(i);
+		}
+		return bytes;
+	}
+
+	// Keys are encoded as base64url, so that they can be used in URLs as is.
+	function toBase64URL(bytes) {
+		return toBase64(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	}
+
+	function fromBase64URL(s) {
+		s = s.replace(/-/g, "+").replace(/_/g, "/");
+		while (s.length % 4 != 0) {
+			s += "=";
+		}
+		return fromBase64(s);
+	}
+
+	async function encrypt(plaintext) {
+		var key = await crypto.subtle.generateKey({ name: "AES-GCM", length: 256 }, true, ["encrypt"]);
+		var iv = crypto.getRandomValues(new Uint8Array(ivLength));
+		var ciphertext = await crypto.subtle.encrypt({ name: "AES-GCM", iv: iv }, key, new TextEncoder().encode(plaintext));
+		var rawKey = await crypto.subtle.exportKey("raw", key);
+
+		var payload = new Uint8Array(ivLength + ciphertext.byteLength);
+		payload.set(iv);
+		payload.set(new Uint8Array(ciphertext), ivLength);
+
+		return { content: toBase64(payload), key: toBase64URL(new Uint8Array(rawKey)) };
+	}
+
+	async function decrypt(content, encodedKey) {
+		var key = await crypto.subtle.importKey("raw", fromBase64URL(encodedKey), "AES-GCM", false, ["decrypt"]);
+		var payload = fromBase64(content);
+		var plaintext = await crypto.subtle.decrypt({ name: "AES-GCM", iv: payload.slice(0, ivLength) }, key, payload.slice(ivLength));
+
+		return new TextDecoder().decode(plaintext);
+	}
+
+	function showErrors(form, messages) {
+		var div = form.querySelector("div.error");
+		if (!div) {
+			div = document.createElement("div");
+			div.className = "error";
+			form.insertBefore(div, form.querySelector("div"));
+		}
+		div.textContent = messages.join(" ");
+	}
+
+	// Creation form, whose content is encrypted before being sent as JSON.
+	var form = document.querySelector("form.encrypted");
+	if (form) {
+		form.addEventListener("submit", async function (event) {
+			event.preventDefault();
+
+			try {
+				var content = document.getElementById("content").value;
+				var encrypted = await encrypt(content);
+
+				var response = await fetch(form.action, {
+					method: "POST",
+					headers: {
+						"Content-Type": "application/json",
+						"X-CSRF-Token": form.elements.csrf_token.value,
+					},
+					body: JSON.stringify({
+						title: form.elements.title.value,
+						content: content == "" ? "" : encrypted.content,
+						visibility: form.elements.visibility.value,
+						burn: form.elements.burn.checked,
+						expires: form.elements.expires.value,
+						expires_at: form.elements.expires_at.value,
+					}),
+				});
+				var body = await response.json();
+
+				if (!response.ok) {
+					var messages = [];
+					for (var field in body.errors || {}) {
+						messages.push(field.charAt(0).toUpperCase() + field.slice(1) + ": " + body.errors[field] + ".");
+					}
+					showErrors(form, messages.length > 0 ? messages : [body.error || "The note couldn't be created."]);
+					return;
+				}
+
+				window.location.assign(body.url + "#" + encrypted.key);
+			} catch (err) {
+				showErrors(form, ["The note couldn't be created, please try again."]);
+			}
+		});
+	}
+
+	var key = window.location.hash.slice(1);
+
+	// Forms leading back to the note keep the key, so that it can still be decrypted afterwards.
+	var forms = document.querySelectorAll("form[data-keep-fragment]");
+	for (var i = 0; i < forms.length; i++) {
+		if (key != "") {
+			forms[i].action += "#" + key;
+		}
+	}
+
+	var elements = document.querySelectorAll("[data-ciphertext]");
+	elements.forEach(function (el) {
+		if (key == "") {
+			el.textContent = "This note can't be read without the key included in its link.";
+			return;
+		}
+
+		decrypt(el.dataset.ciphertext, key).then(function (plaintext) {
+			el.textContent = plaintext;
+		}).catch(function () {
+			el.textContent = "This note couldn't be decrypted, its link may be incomplete.";
+		});
+	});
+})();